@@ -6,19 +6,27 @@ import (
 	// "time"
 
 	// "github.com/jfernsio/slotswapper/internals/config"
+	"github.com/jfernsio/slotswapper/internals/auth"
 	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/jobs"
+	"github.com/jfernsio/slotswapper/internals/mailer"
 	"github.com/jfernsio/slotswapper/internals/middleware"
 	"github.com/jfernsio/slotswapper/internals/routes"
+	"github.com/jfernsio/slotswapper/internals/storage"
 )
 
 func main() {
 	database.Init()
+	mailer.Init()
+	auth.Init(database.DB)
+	storage.Init()
+	jobs.Start(database.DB)
 
 	mux := http.NewServeMux()
 	routes.RegisterRoutes(mux)
 	
 
-	log.Printf("🚀 Server running on http://localhost:", 8080)
+	log.Printf("🚀 Server running on http://localhost:%d", 8080)
 	http.ListenAndServe(":8080", middleware.EnableCORS(mux))
 
 }
@@ -0,0 +1,97 @@
+package access
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+// Permission names a capability checked against the caller's team role.
+type Permission string
+
+const (
+	ViewTeam            Permission = "team:view"
+	CreateOwnEvent      Permission = "event:create-own"
+	SwapSlots           Permission = "swap:create"
+	ClaimShifts         Permission = "shift:claim"
+	InviteMembers       Permission = "team:invite"
+	ManageEvents        Permission = "event:manage"
+	ReassignSlots       Permission = "event:reassign"
+	RevertSwaps         Permission = "swap:revert"
+	ApproveSwaps        Permission = "swap:approve"
+	GrantQualifications Permission = "team:qualifications"
+	ManageMembers       Permission = "team:members"
+	ManageTeam          Permission = "team:settings"
+)
+
+var rolePermissions = map[models.TeamRole][]Permission{
+	models.RoleMember: {ViewTeam, CreateOwnEvent, SwapSlots, ClaimShifts},
+	models.RoleManager: {ViewTeam, CreateOwnEvent, SwapSlots, ClaimShifts, InviteMembers,
+		ManageEvents, ReassignSlots, RevertSwaps, ApproveSwaps, GrantQualifications},
+	models.RoleAdmin: {ViewTeam, CreateOwnEvent, SwapSlots, ClaimShifts, InviteMembers,
+		ManageEvents, ReassignSlots, RevertSwaps, ApproveSwaps, GrantQualifications,
+		ManageMembers, ManageTeam},
+}
+
+// ErrNotMember is returned when the user has no role in the team.
+var ErrNotMember = errors.New("not a member of this team")
+
+// RoleFor returns the user's role in a team. The owner of the team's
+// organization is an admin of every team in it, member or not.
+func RoleFor(userID, teamID uint) (models.TeamRole, error) {
+	var m models.TeamMembership
+	err := database.DB.Where("team_id = ? AND user_id = ?", teamID, userID).First(&m).Error
+	if err == nil {
+		if isOrgOwner(userID, teamID) {
+			return models.RoleAdmin, nil
+		}
+		return m.Role, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if isOrgOwner(userID, teamID) {
+		return models.RoleAdmin, nil
+	}
+	return "", ErrNotMember
+}
+
+func isOrgOwner(userID, teamID uint) bool {
+	var n int64
+	database.DB.Model(&models.Team{}).
+		Joins("JOIN organizations ON organizations.id = teams.organization_id").
+		Where("teams.id = ? AND organizations.owner_id = ?", teamID, userID).
+		Count(&n)
+	return n > 0
+}
+
+// RoleAllows reports whether role grants perm.
+func RoleAllows(role models.TeamRole, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the user holds perm in the team. A non-member gets
+// (false, nil) rather than an error.
+func Can(userID, teamID uint, perm Permission) (bool, error) {
+	role, err := RoleFor(userID, teamID)
+	if errors.Is(err, ErrNotMember) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return RoleAllows(role, perm), nil
+}
+
+// Permissions lists everything a role may do, for clients to adapt their UI.
+func Permissions(role models.TeamRole) []Permission {
+	return append([]Permission(nil), rolePermissions[role]...)
+}
@@ -0,0 +1,188 @@
+// Package audit writes and checks the hash-chained audit log.
+//
+// Actions are named "<area>.<verb>", for example "auth.login" or
+// "swap.accepted", so they can be filtered by prefix.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/utils"
+)
+
+// GenesisHash is the PrevHash of the first entry.
+var GenesisHash = strings.Repeat("0", 64)
+
+// lockKey serialises writers so every entry links to the one before it.
+const lockKey = 0x61756469
+
+// FromRequest starts an entry for action taken by actorID (nil when nobody
+// is signed in), filling in the caller's IP and user agent.
+func FromRequest(r *http.Request, actorID *uint, action string) models.AuditEntry {
+	ua := r.UserAgent()
+	if len(ua) > 300 {
+		ua = ua[:300]
+	}
+	return models.AuditEntry{
+		ActorID:   actorID,
+		Action:    action,
+		IP:        utils.ClientIP(r),
+		UserAgent: ua,
+	}
+}
+
+// Record appends the entry to the chain. CreatedAt, PrevHash and Hash are
+// set here.
+func Record(db *gorm.DB, entry *models.AuditEntry) error {
+	details, err := normalize(entry.Details)
+	if err != nil {
+		return err
+	}
+	entry.Details = details
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+		var last models.AuditEntry
+		err := tx.Select("hash").Order("id DESC").Take(&last).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			entry.PrevHash = GenesisHash
+		case err != nil:
+			return err
+		default:
+			entry.PrevHash = last.Hash
+		}
+		// Postgres keeps microseconds, so hash what will be read back
+		entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		entry.Hash = Hash(*entry)
+		return tx.Create(entry).Error
+	})
+}
+
+// normalize round-trips details through JSON so they hash the same before
+// writing and after reading back from jsonb.
+func normalize(details models.AuditDetails) (models.AuditDetails, error) {
+	if len(details) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+	var out models.AuditDetails
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
+
+// Hash computes the entry's hash from its fields and PrevHash.
+func Hash(e models.AuditEntry) string {
+	b, _ := json.Marshal(struct {
+		PrevHash   string              `json:"prevHash"`
+		ActorID    *uint               `json:"actorId"`
+		Action     string              `json:"action"`
+		TargetType string              `json:"targetType"`
+		TargetID   *uint               `json:"targetId"`
+		IP         string              `json:"ip"`
+		UserAgent  string              `json:"userAgent"`
+		Details    models.AuditDetails `json:"details"`
+		CreatedAt  string              `json:"createdAt"`
+	}{
+		PrevHash:   e.PrevHash,
+		ActorID:    e.ActorID,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.IP,
+		UserAgent:  e.UserAgent,
+		Details:    e.Details,
+		CreatedAt:  e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Result is the outcome of checking the chain.
+type Result struct {
+	Checked  int    `json:"checked"`
+	Valid    bool   `json:"valid"`
+	BrokenAt *uint  `json:"brokenAt,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Verify walks the whole log in order and reports the first entry whose
+// hash or link doesn't match.
+func Verify(db *gorm.DB) (Result, error) {
+	res := Result{Valid: true}
+	prev := GenesisHash
+	var batch []models.AuditEntry
+	err := db.Order("id").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, e := range batch {
+			res.Checked++
+			switch {
+			case e.PrevHash != prev:
+				res.Reason = "previous hash does not match"
+			case Hash(e) != e.Hash:
+				res.Reason = "entry hash does not match its contents"
+			default:
+				prev = e.Hash
+				continue
+			}
+			id := e.ID
+			res.Valid, res.BrokenAt = false, &id
+			return errStop
+		}
+		return nil
+	}).Error
+	if errors.Is(err, errStop) {
+		err = nil
+	}
+	return res, err
+}
+
+var errStop = errors.New("stop")
+
+// Filter narrows a query over the log. Zero fields match everything. An
+// Action ending in ".*" matches every action with that prefix.
+type Filter struct {
+	ActorID    *uint
+	Action     string
+	TargetType string
+	TargetID   *uint
+	From       *time.Time
+	To         *time.Time
+}
+
+// Apply adds the filter's conditions to the query.
+func (f Filter) Apply(db *gorm.DB) *gorm.DB {
+	if f.ActorID != nil {
+		db = db.Where("actor_id = ?", *f.ActorID)
+	}
+	if prefix, ok := strings.CutSuffix(f.Action, ".*"); ok {
+		db = db.Where("action LIKE ?", prefix+".%")
+	} else if f.Action != "" {
+		db = db.Where("action = ?", f.Action)
+	}
+	if f.TargetType != "" {
+		db = db.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != nil {
+		db = db.Where("target_id = ?", *f.TargetID)
+	}
+	if f.From != nil {
+		db = db.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		db = db.Where("created_at < ?", *f.To)
+	}
+	return db
+}
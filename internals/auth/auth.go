@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/config"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+var (
+	ErrEmailTaken         = errors.New("email already registered")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrUnknownUser is an ErrInvalidCredentials; callers may only tell the
+	// two apart for logging, never in responses.
+	ErrUnknownUser  = fmt.Errorf("%w: unknown user", ErrInvalidCredentials)
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// ValidationError is returned for input the caller can fix.
+type ValidationError struct {
+	Field string
+	Msg   string
+}
+
+func (e *ValidationError) Error() string { return e.Msg }
+
+// SignupInput is the data needed to create an account.
+type SignupInput struct {
+	Name     string
+	Email    string
+	Password string
+}
+
+// Service holds account logic shared by the HTTP handlers.
+type Service interface {
+	Signup(ctx context.Context, in SignupInput) (*models.User, error)
+	Authenticate(ctx context.Context, email, password string) (*models.User, error)
+	ChangePassword(ctx context.Context, userID uint, current, next string) error
+	Policy() config.PasswordPolicy
+}
+
+// Default is the service used by the handlers; set by Init.
+var Default Service
+
+// Init wires Default to db with the policy from the environment.
+func Init(db *gorm.DB) {
+	Default = NewService(db, config.GetPasswordPolicy())
+}
+
+// simpleEmailRegexp is intentionally forgiving — it's fine for demo.
+var simpleEmailRegexp = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+
+// dummyHash is compared against when the email is unknown so the response
+// time does not reveal whether the account exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("slotswapper-timing-equaliser"), bcrypt.DefaultCost)
+
+type service struct {
+	db     *gorm.DB
+	policy config.PasswordPolicy
+}
+
+func NewService(db *gorm.DB, policy config.PasswordPolicy) Service {
+	return &service{db: db, policy: policy}
+}
+
+func (s *service) Policy() config.PasswordPolicy { return s.policy }
+
+// NormalizeEmail trims and lowercases an address for storage and lookup.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidateEmail checks an already normalised address.
+func ValidateEmail(email string) error {
+	if email == "" {
+		return &ValidationError{Field: "email", Msg: "email is required"}
+	}
+	if !simpleEmailRegexp.MatchString(email) {
+		return &ValidationError{Field: "email", Msg: "invalid email"}
+	}
+	return nil
+}
+
+// normalizeSignup trims the name and email and validates the result. The
+// password is stored as typed, but surrounding spaces don't count towards its
+// length, so padding can't stretch a short password past the minimum.
+func (s *service) normalizeSignup(in SignupInput) (SignupInput, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	in.Email = NormalizeEmail(in.Email)
+
+	if in.Name == "" {
+		return in, &ValidationError{Field: "name", Msg: "name is required"}
+	}
+	if err := ValidateEmail(in.Email); err != nil {
+		return in, err
+	}
+	if err := ValidatePassword(s.policy, in.Password); err != nil {
+		return in, err
+	}
+	return in, nil
+}
+
+func (s *service) Signup(ctx context.Context, in SignupInput) (*models.User, error) {
+	in, err := s.normalizeSignup(in)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.db.WithContext(ctx)
+	var existing models.User
+	if err := db.Where("LOWER(email) = ?", in.Email).First(&existing).Error; err == nil {
+		return nil, ErrEmailTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(in.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Name:     in.Name,
+		Email:    in.Email,
+		Password: string(hash),
+	}
+	if err := db.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Authenticate checks an email/password pair. On a wrong password the user is
+// still returned alongside ErrInvalidCredentials so callers can attribute the
+// failed attempt.
+func (s *service) Authenticate(ctx context.Context, email, password string) (*models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Where("LOWER(email) = ?", NormalizeEmail(email)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrUnknownUser
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return &user, ErrInvalidCredentials
+	}
+	return &user, nil
+}
+
+func (s *service) ChangePassword(ctx context.Context, userID uint, current, next string) error {
+	db := s.db.WithContext(ctx)
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(current)) != nil {
+		return ErrInvalidCredentials
+	}
+	if err := ValidatePassword(s.policy, next); err != nil {
+		return err
+	}
+	if current == next {
+		return &ValidationError{Field: "newPassword", Msg: "new password must differ from the current one"}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(next), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return db.Model(&user).Update("password", string(hash)).Error
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/jfernsio/slotswapper/internals/config"
+)
+
+// ValidatePassword returns a ValidationError describing the first rule of p
+// that password breaks. Surrounding spaces don't count towards the length.
+func ValidatePassword(p config.PasswordPolicy, password string) error {
+	trimmed := strings.TrimSpace(password)
+	if trimmed == "" {
+		return &ValidationError{Field: "password", Msg: "password is required"}
+	}
+	if len([]rune(trimmed)) < p.MinLength {
+		return &ValidationError{Field: "password", Msg: fmt.Sprintf("password must be at least %d characters", p.MinLength)}
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			symbol = true
+		}
+	}
+	switch {
+	case p.RequireUpper && !upper:
+		return &ValidationError{Field: "password", Msg: "password must contain an uppercase letter"}
+	case p.RequireLower && !lower:
+		return &ValidationError{Field: "password", Msg: "password must contain a lowercase letter"}
+	case p.RequireDigit && !digit:
+		return &ValidationError{Field: "password", Msg: "password must contain a digit"}
+	case p.RequireSymbol && !symbol:
+		return &ValidationError{Field: "password", Msg: "password must contain a symbol"}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Package bus fans domain events out to the users they concern. Each event
+// is stored as a notification before it is published, so a client that
+// missed it can catch up by notification ID.
+package bus
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+// Event types.
+const (
+	SwapCreated          = "swap.created"
+	SwapAccepted         = "swap.accepted"
+	SwapAwaitingApproval = "swap.awaiting_approval"
+	SwapRejected         = "swap.rejected"
+	SwapReverted         = "swap.reverted"
+	SwapExpired          = "swap.expired"
+	SwapCancelled        = "swap.cancelled"
+	EventChanged         = "event.changed"
+	SlotMatched          = "slot.matched"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// it is dropped.
+const subscriberBuffer = 64
+
+// Bus delivers published notifications to the subscriptions of their user.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[uint]map[*Subscription]struct{}
+}
+
+// Default is the process-wide bus.
+var Default = New()
+
+func New() *Bus {
+	return &Bus{subs: map[uint]map[*Subscription]struct{}{}}
+}
+
+// Subscription receives one user's notifications on C. C is closed when
+// the subscription is closed or falls too far behind; the subscriber should
+// then resume from the database.
+type Subscription struct {
+	C <-chan models.Notification
+
+	c      chan models.Notification
+	bus    *Bus
+	userID uint
+	once   sync.Once
+}
+
+// Subscribe starts receiving the user's notifications.
+func (b *Bus) Subscribe(userID uint) *Subscription {
+	c := make(chan models.Notification, subscriberBuffer)
+	s := &Subscription{C: c, c: c, bus: b, userID: userID}
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = map[*Subscription]struct{}{}
+	}
+	b.subs[userID][s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.closeLocked()
+}
+
+func (s *Subscription) closeLocked() {
+	s.once.Do(func() {
+		delete(s.bus.subs[s.userID], s)
+		if len(s.bus.subs[s.userID]) == 0 {
+			delete(s.bus.subs, s.userID)
+		}
+		close(s.c)
+	})
+}
+
+// CloseUser closes every subscription of the user, for when the account is
+// deleted.
+func (b *Bus) CloseUser(userID uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs[userID] {
+		s.closeLocked()
+	}
+}
+
+// Publish hands n to the user's subscribers without blocking. A subscriber
+// whose buffer is full is closed rather than silently skipped.
+func (b *Bus) Publish(n models.Notification) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs[n.UserID] {
+		select {
+		case s.c <- n:
+		default:
+			s.closeLocked()
+		}
+	}
+}
+
+// Emit stores a notification of the given type for each user and publishes
+// it. Call it once the change has committed, so nobody hears about a change
+// that was rolled back. Zero and repeated user IDs are skipped.
+func (b *Bus) Emit(db *gorm.DB, typ string, data models.JSONMap, userIDs ...uint) error {
+	seen := map[uint]bool{0: true}
+	var notes []models.Notification
+	for _, id := range userIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		notes = append(notes, models.Notification{UserID: id, Type: typ, Data: data})
+	}
+	if len(notes) == 0 {
+		return nil
+	}
+	if err := db.Create(&notes).Error; err != nil {
+		return err
+	}
+	for _, n := range notes {
+		b.Publish(n)
+	}
+	return nil
+}
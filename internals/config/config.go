@@ -2,7 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -32,9 +36,208 @@ func GetPort() string {
 	return getEnv("PORT", "8080")
 }
 
+// GetAppURL is the public base URL used when building links sent to users.
+func GetAppURL() string {
+	return getEnv("APP_URL", "http://localhost:"+GetPort())
+}
+
+func GetMailDriver() string {
+	return getEnv("MAIL_DRIVER", "log")
+}
+
+func GetMailFrom() string {
+	return getEnv("MAIL_FROM", "SlotSwapper <no-reply@slotswapper.local>")
+}
+
+func GetMailDir() string {
+	return getEnv("MAIL_DIR", "./data/mail")
+}
+
+// GetSMTPConfig reads the SMTP driver settings. The connection is upgraded
+// with STARTTLS whenever the server offers it.
+func GetSMTPConfig() (host string, port int, username, password string) {
+	return os.Getenv("SMTP_HOST"), getInt("SMTP_PORT", 587), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD")
+}
+
+// GetMailQueueInterval is how often the sender job drains the mail queue.
+func GetMailQueueInterval() time.Duration {
+	return getDuration("MAIL_QUEUE_INTERVAL", 30*time.Second)
+}
+
+// GetMailMaxAttempts is how many times a queued email is tried before it is
+// marked failed.
+func GetMailMaxAttempts() int {
+	return getInt("MAIL_MAX_ATTEMPTS", 5)
+}
+
+// GetDigestInterval is how often the digest job looks for users whose
+// digest hour has come.
+func GetDigestInterval() time.Duration {
+	return getDuration("DIGEST_INTERVAL", 15*time.Minute)
+}
+
+func GetEmailVerificationTTL() time.Duration {
+	return getDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+}
+
+func GetStorageDriver() string {
+	return getEnv("STORAGE_DRIVER", "local")
+}
+
+// GetStorageDir is the root directory for the local storage driver.
+func GetStorageDir() string {
+	return getEnv("STORAGE_DIR", "./data/uploads")
+}
+
+// GetTrashRetention is how long deleted events stay restorable before the
+// purge job removes them for good.
+func GetTrashRetention() time.Duration {
+	return getDuration("EVENT_TRASH_RETENTION", 30*24*time.Hour)
+}
+
+// GetTrashPurgeInterval is how often the purge job runs.
+func GetTrashPurgeInterval() time.Duration {
+	return getDuration("EVENT_TRASH_PURGE_INTERVAL", time.Hour)
+}
+
+// GetSwapExpiryInterval is how often open swaps whose slots have started
+// are expired.
+func GetSwapExpiryInterval() time.Duration {
+	return getDuration("SWAP_EXPIRY_INTERVAL", 5*time.Minute)
+}
+
+// GetMarketplaceConnectionLimit caps how many live marketplace sockets one
+// user may hold open at once.
+func GetMarketplaceConnectionLimit() int {
+	return getInt("MARKETPLACE_MAX_CONNECTIONS", 5)
+}
+
+// GetWebSocketOrigins lists the browser origins, besides the server's own,
+// that may open WebSockets. It defaults to the origin of APP_URL.
+func GetWebSocketOrigins() []string {
+	var origins []string
+	for _, o := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	if len(origins) == 0 {
+		if u, err := url.Parse(GetAppURL()); err == nil && u.Host != "" {
+			origins = append(origins, u.Scheme+"://"+u.Host)
+		}
+	}
+	return origins
+}
+
+// GetWebhookInterval is how often pending webhook deliveries are sent.
+func GetWebhookInterval() time.Duration {
+	return getDuration("WEBHOOK_INTERVAL", 15*time.Second)
+}
+
+// GetWebhookMaxAttempts is how many times a delivery is tried before it is
+// marked failed.
+func GetWebhookMaxAttempts() int {
+	return getInt("WEBHOOK_MAX_ATTEMPTS", 8)
+}
+
+// GetWebhookTimeout bounds each delivery request.
+func GetWebhookTimeout() time.Duration {
+	return getDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+}
+
+// WebhookAllowPrivate lets webhooks target loopback and private network
+// addresses. Leave it off in production so webhooks can't reach internal
+// services.
+func WebhookAllowPrivate() bool {
+	return getBool("WEBHOOK_ALLOW_PRIVATE", false)
+}
+
+// GetAuditAdmins lists the emails, lowercased, of the users allowed to read
+// the audit log, from a comma-separated AUDIT_ADMIN_EMAILS.
+func GetAuditAdmins() []string {
+	var admins []string
+	for _, email := range strings.Split(os.Getenv("AUDIT_ADMIN_EMAILS"), ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			admins = append(admins, email)
+		}
+	}
+	return admins
+}
+
+// TrustProxy makes the server honour X-Forwarded-For for the client IP.
+// Only enable it behind a reverse proxy that sets the header.
+func TrustProxy() bool {
+	return getBool("TRUST_PROXY", false)
+}
+
+// LoginThrottle controls failed-login backoff and lockout.
+type LoginThrottle struct {
+	MaxAccountFailures int
+	MaxIPFailures      int
+	Window             time.Duration
+	BaseLockout        time.Duration
+	MaxLockout         time.Duration
+}
+
+func GetLoginThrottle() LoginThrottle {
+	return LoginThrottle{
+		MaxAccountFailures: getInt("LOGIN_MAX_FAILURES", 5),
+		MaxIPFailures:      getInt("LOGIN_IP_MAX_FAILURES", 20),
+		Window:             getDuration("LOGIN_FAILURE_WINDOW", time.Hour),
+		BaseLockout:        getDuration("LOGIN_LOCKOUT_BASE", 30*time.Second),
+		MaxLockout:         getDuration("LOGIN_LOCKOUT_MAX", time.Hour),
+	}
+}
+
+// PasswordPolicy describes what a new password must contain.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+func GetPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     getInt("PASSWORD_MIN_LENGTH", 6),
+		RequireUpper:  getBool("PASSWORD_REQUIRE_UPPER", false),
+		RequireLower:  getBool("PASSWORD_REQUIRE_LOWER", false),
+		RequireDigit:  getBool("PASSWORD_REQUIRE_DIGIT", false),
+		RequireSymbol: getBool("PASSWORD_REQUIRE_SYMBOL", false),
+	}
+}
+
 func getEnv(key, def string) string {
 	if val := os.Getenv(key); val != "" {
 		return val
 	}
 	return def
 }
+
+func getDuration(key string, def time.Duration) time.Duration {
+	if val := os.Getenv(key); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+func getInt(key string, def int) int {
+	if val := os.Getenv(key); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+func getBool(key string, def bool) bool {
+	if val := os.Getenv(key); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	}
+	return def
+}
@@ -36,9 +36,24 @@ func Init() {
 		log.Fatalf("❌ db ping failed: %v", err)
 	}
 
-	if err := db.AutoMigrate(&models.User{}, &models.Event{}, &models.SwapRequest{}); err != nil {
+	// accounts from before email verification existed count as verified;
+	// only new signups have to confirm their address
+	backfillVerified := db.Migrator().HasTable(&models.User{}) && !db.Migrator().HasColumn(&models.User{}, "EmailVerified")
+
+	if err := db.AutoMigrate(&models.User{}, &models.Event{}, &models.SwapRequest{}, &models.EmailVerification{}, &models.RecoveryCode{}, &models.LoginAttempt{},
+		&models.Organization{}, &models.Team{}, &models.TeamMembership{}, &models.TeamInvitation{},
+		&models.TeamLaborRules{}, &models.TeamSwapPolicy{}, &models.SwapBlackout{}, &models.ShiftClaim{},
+		&models.Rotation{}, &models.RotationExclusion{}, &models.AvailabilityBlock{}, &models.PreferredHours{},
+		&models.EventRevision{}, &models.AuditEntry{},
+		&models.Notification{}, &models.SavedSearch{}, &models.QueuedMail{},
+		&models.Webhook{}, &models.WebhookDelivery{}); err != nil {
 		log.Fatalf("❌ migration failed: %v", err)
 	}
+	if backfillVerified {
+		if err := db.Model(&models.User{}).Where("email_verified = ?", false).Update("email_verified", true).Error; err != nil {
+			log.Fatalf("❌ email verification backfill failed: %v", err)
+		}
+	}
 
 	DB = db
 	log.Println("✅ Database connected & migrated")
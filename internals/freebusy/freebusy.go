@@ -0,0 +1,99 @@
+package freebusy
+
+import (
+	"sort"
+	"time"
+)
+
+// Interval is a half-open time range [Start, End).
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Merge sorts the intervals and joins those that overlap or touch, clipped
+// to the window.
+func Merge(intervals []Interval, window Interval) []Interval {
+	clipped := make([]Interval, 0, len(intervals))
+	for _, iv := range intervals {
+		if iv.Start.Before(window.Start) {
+			iv.Start = window.Start
+		}
+		if iv.End.After(window.End) {
+			iv.End = window.End
+		}
+		if iv.End.After(iv.Start) {
+			clipped = append(clipped, iv)
+		}
+	}
+	sort.Slice(clipped, func(i, j int) bool { return clipped[i].Start.Before(clipped[j].Start) })
+
+	out := []Interval{}
+	for _, iv := range clipped {
+		if n := len(out); n > 0 && !iv.Start.After(out[n-1].End) {
+			if iv.End.After(out[n-1].End) {
+				out[n-1].End = iv.End
+			}
+			continue
+		}
+		out = append(out, iv)
+	}
+	return out
+}
+
+// isFree reports whether [start, end) misses every merged busy interval.
+func isFree(busy []Interval, start, end time.Time) bool {
+	// busy is sorted, so find the first interval ending after start
+	i := sort.Search(len(busy), func(i int) bool { return busy[i].End.After(start) })
+	return i == len(busy) || !busy[i].Start.Before(end)
+}
+
+// Proposal is a candidate meeting slot and who can attend it.
+type Proposal struct {
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Available   []uint    `json:"available"`
+	Unavailable []uint    `json:"unavailable"`
+}
+
+// Find proposes slots of the given duration inside the window where at
+// least quorum of the users are free. Candidates start on step boundaries
+// and proposals don't overlap. busy must be merged per user.
+func Find(busy map[uint][]Interval, window Interval, duration, step time.Duration, quorum, limit int) []Proposal {
+	users := make([]uint, 0, len(busy))
+	for id := range busy {
+		users = append(users, id)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+
+	var out []Proposal
+	start := nextBoundary(window.Start, step)
+	for !start.Add(duration).After(window.End) && len(out) < limit {
+		end := start.Add(duration)
+		p := Proposal{Start: start, End: end, Available: []uint{}, Unavailable: []uint{}}
+		for _, id := range users {
+			if isFree(busy[id], start, end) {
+				p.Available = append(p.Available, id)
+			} else {
+				p.Unavailable = append(p.Unavailable, id)
+			}
+		}
+		if len(p.Available) < quorum {
+			start = start.Add(step)
+			continue
+		}
+		out = append(out, p)
+		// the next candidate is the first boundary at or after this one's end
+		start = nextBoundary(end, step)
+	}
+	return out
+}
+
+// nextBoundary rounds t up to a multiple of step.
+func nextBoundary(t time.Time, step time.Duration) time.Time {
+	b := t.Truncate(step)
+	if b.Before(t) {
+		b = b.Add(step)
+	}
+	return b
+}
@@ -0,0 +1,136 @@
+package freebusy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func at(clock string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", "2026-03-02 "+clock)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func iv(start, end string) Interval {
+	return Interval{Start: at(start), End: at(end)}
+}
+
+func TestMerge(t *testing.T) {
+	window := iv("08:00", "18:00")
+	tests := []struct {
+		name string
+		in   []Interval
+		want []Interval
+	}{
+		{"empty", nil, []Interval{}},
+		{"unsorted and separate", []Interval{iv("13:00", "14:00"), iv("09:00", "10:00")},
+			[]Interval{iv("09:00", "10:00"), iv("13:00", "14:00")}},
+		{"overlapping", []Interval{iv("09:00", "11:00"), iv("10:00", "12:00")},
+			[]Interval{iv("09:00", "12:00")}},
+		{"touching", []Interval{iv("09:00", "10:00"), iv("10:00", "11:00")},
+			[]Interval{iv("09:00", "11:00")}},
+		{"contained", []Interval{iv("09:00", "15:00"), iv("10:00", "11:00"), iv("12:00", "13:00")},
+			[]Interval{iv("09:00", "15:00")}},
+		{"clipped to the window", []Interval{iv("06:00", "09:00"), iv("17:00", "20:00")},
+			[]Interval{iv("08:00", "09:00"), iv("17:00", "18:00")}},
+		{"outside the window", []Interval{iv("05:00", "07:00"), iv("18:00", "19:00")}, []Interval{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Merge(tt.in, window); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsFree(t *testing.T) {
+	busy := []Interval{iv("09:00", "10:00"), iv("12:00", "13:00")}
+	tests := []struct {
+		start, end string
+		want       bool
+	}{
+		{"08:00", "09:00", true},  // ends as the first busy block starts
+		{"10:00", "12:00", true},  // exactly the gap
+		{"13:00", "14:00", true},  // after everything
+		{"08:30", "09:30", false}, // overlaps the start of a block
+		{"09:15", "09:45", false}, // inside a block
+		{"11:00", "14:00", false}, // spans a block
+		{"09:59", "10:30", false}, // overlaps the end of a block
+	}
+	for _, tt := range tests {
+		if got := isFree(busy, at(tt.start), at(tt.end)); got != tt.want {
+			t.Errorf("isFree(%s-%s) = %v, want %v", tt.start, tt.end, got, tt.want)
+		}
+	}
+	if !isFree(nil, at("09:00"), at("10:00")) {
+		t.Error("nobody busy should be free")
+	}
+}
+
+func starts(ps []Proposal) []string {
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = p.Start.Format("15:04")
+	}
+	return out
+}
+
+func TestFindStartsOnStepBoundaries(t *testing.T) {
+	busy := map[uint][]Interval{1: {}}
+	got := Find(busy, iv("09:00", "12:00"), 50*time.Minute, 30*time.Minute, 1, 10)
+	want := []string{"09:00", "10:00", "11:00"}
+	if !reflect.DeepEqual(starts(got), want) {
+		t.Errorf("starts = %v, want %v", starts(got), want)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Start.Before(got[i-1].End) {
+			t.Errorf("proposals %d and %d overlap", i-1, i)
+		}
+	}
+}
+
+func TestFindRoundsTheWindowStartUp(t *testing.T) {
+	busy := map[uint][]Interval{1: {}}
+	got := Find(busy, iv("09:10", "11:00"), 30*time.Minute, 30*time.Minute, 1, 10)
+	want := []string{"09:30", "10:00", "10:30"}
+	if !reflect.DeepEqual(starts(got), want) {
+		t.Errorf("starts = %v, want %v", starts(got), want)
+	}
+}
+
+func TestFindQuorumAndLimit(t *testing.T) {
+	busy := map[uint][]Interval{
+		1: {iv("09:00", "10:00")},
+		2: {iv("09:30", "11:00")},
+		3: {},
+	}
+	window := iv("09:00", "12:00")
+
+	all := Find(busy, window, time.Hour, 30*time.Minute, 3, 10)
+	if want := []string{"11:00"}; !reflect.DeepEqual(starts(all), want) {
+		t.Fatalf("with everyone required, starts = %v, want %v", starts(all), want)
+	}
+
+	two := Find(busy, window, time.Hour, 30*time.Minute, 2, 10)
+	if want := []string{"10:00", "11:00"}; !reflect.DeepEqual(starts(two), want) {
+		t.Fatalf("with a quorum of 2, starts = %v, want %v", starts(two), want)
+	}
+	if first := two[0]; !reflect.DeepEqual(first.Available, []uint{1, 3}) || !reflect.DeepEqual(first.Unavailable, []uint{2}) {
+		t.Errorf("10:00 available %v unavailable %v, want [1 3] and [2]", first.Available, first.Unavailable)
+	}
+
+	if got := Find(busy, window, time.Hour, 30*time.Minute, 2, 1); len(got) != 1 {
+		t.Errorf("limit 1 returned %d proposals", len(got))
+	}
+}
+
+func TestFindNothingFits(t *testing.T) {
+	busy := map[uint][]Interval{1: {}}
+	if got := Find(busy, iv("09:00", "09:45"), time.Hour, 15*time.Minute, 1, 10); len(got) != 0 {
+		t.Errorf("got %v for a window shorter than the duration", starts(got))
+	}
+}
@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/auth"
+	"github.com/jfernsio/slotswapper/internals/bus"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/market"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/storage"
+)
+
+// AccountExport is everything stored about a user.
+type AccountExport struct {
+	ExportedAt     time.Time                  `json:"exportedAt"`
+	Profile        ProfileResponse            `json:"profile"`
+	Events         []models.Event             `json:"events"`
+	SwapsSent      []models.SwapRequest       `json:"swapsSent"`
+	SwapsReceived  []models.SwapRequest       `json:"swapsReceived"`
+	LoginAttempts  []models.LoginAttempt      `json:"loginAttempts"`
+	Availability   []models.AvailabilityBlock `json:"availability"`
+	PreferredHours []models.PreferredHours    `json:"preferredHours"`
+	AuditLog       []models.AuditEntry        `json:"auditLog"`
+	SavedSearches  []models.SavedSearch       `json:"savedSearches"`
+	Notifications  []models.Notification      `json:"notifications"`
+	Emails         []models.QueuedMail        `json:"emails"`
+}
+
+func buildAccountExport(user models.User) (*AccountExport, error) {
+	export := AccountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile:    profileResponse(user),
+	}
+	if err := database.DB.Where("user_id = ?", user.ID).Order("start_time").Find(&export.Events).Error; err != nil {
+		return nil, err
+	}
+	if err := database.DB.Where("requester_id = ?", user.ID).Order("created_at").Find(&export.SwapsSent).Error; err != nil {
+		return nil, err
+	}
+	if err := database.DB.Where("receiver_id = ?", user.ID).Order("created_at").Find(&export.SwapsReceived).Error; err != nil {
+		return nil, err
+	}
+	if err := database.DB.Where("user_id = ?", user.ID).Order("created_at").Find(&export.LoginAttempts).Error; err != nil {
+		return nil, err
+	}
+	if err := database.DB.Where("user_id = ?", user.ID).Order("start_time").Find(&export.Availability).Error; err != nil {
+		return nil, err
+	}
+	if err := database.DB.Where("user_id = ?", user.ID).Order("weekday, start_time").Find(&export.PreferredHours).Error; err != nil {
+		return nil, err
+	}
+	if err := database.DB.Where("actor_id = ?", user.ID).Order("id").Find(&export.AuditLog).Error; err != nil {
+		return nil, err
+	}
+	if err := database.DB.Where("user_id = ?", user.ID).Order("id").Find(&export.SavedSearches).Error; err != nil {
+		return nil, err
+	}
+	if err := database.DB.Where("user_id = ?", user.ID).Order("id").Find(&export.Notifications).Error; err != nil {
+		return nil, err
+	}
+	if err := database.DB.Where("user_id = ?", user.ID).Order("id").Find(&export.Emails).Error; err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// ExportAccount handles GET /api/account/export?format=json|zip
+func ExportAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, uid).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	export, err := buildAccountExport(user)
+	if err != nil {
+		http.Error(w, "Failed to build export", http.StatusInternalServerError)
+		return
+	}
+
+	stamp := export.ExportedAt.Format("20060102-150405")
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		logAudit(r, "account.exported", "user", uid, models.AuditDetails{"format": "json"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="slotswapper-export-%s.json"`, stamp))
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(export)
+	case "zip":
+		logAudit(r, "account.exported", "user", uid, models.AuditDetails{"format": "zip"})
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="slotswapper-export-%s.zip"`, stamp))
+		if err := writeExportZip(w, r, user, export); err != nil {
+			// headers are already sent, so the best we can do is log it
+			log.Printf("failed to write export zip for user %d: %v", user.ID, err)
+		}
+	default:
+		http.Error(w, "format must be json or zip", http.StatusBadRequest)
+	}
+}
+
+func writeExportZip(w io.Writer, r *http.Request, user models.User, export *AccountExport) error {
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"events.json", export.Events},
+		{"swaps_sent.json", export.SwapsSent},
+		{"swaps_received.json", export.SwapsReceived},
+		{"login_attempts.json", export.LoginAttempts},
+		{"availability.json", export.Availability},
+		{"preferred_hours.json", export.PreferredHours},
+		{"audit_log.json", export.AuditLog},
+		{"saved_searches.json", export.SavedSearches},
+		{"notifications.json", export.Notifications},
+		{"emails.json", export.Emails},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return err
+		}
+	}
+
+	if user.AvatarKey != "" {
+		rc, err := storage.Default.Get(r.Context(), user.AvatarKey)
+		if err == nil {
+			fw, err := zw.Create("avatar." + avatarExtensions[user.AvatarType])
+			if err == nil {
+				_, err = io.Copy(fw, rc)
+			}
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// DeleteAccount handles DELETE /api/account
+// The user row is kept but anonymised so past swaps stay consistent. Pending
+// swaps and shift claims are cancelled. Future team events go to the
+// reassignTo teammate where they are eligible and otherwise become open
+// shifts; personal events are removed. The user then leaves every team and
+// rotation. Organization owners must transfer ownership first.
+func DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Password   string `json:"password"`
+		Code       string `json:"code"`
+		ReassignTo *uint  `json:"reassignTo"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, uid).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)) != nil {
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	if input.ReassignTo != nil {
+		var target models.User
+		if *input.ReassignTo == user.ID ||
+			database.DB.Where("id = ? AND anonymized_at IS NULL", *input.ReassignTo).First(&target).Error != nil {
+			http.Error(w, "Invalid reassignTo user", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// an organization can't be left without an owner
+	var owned int64
+	if err := database.DB.Model(&models.Organization{}).Where("owner_id = ?", user.ID).Count(&owned).Error; err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if owned > 0 {
+		http.Error(w, "Transfer your organizations to another member before deleting your account", http.StatusConflict)
+		return
+	}
+
+	avatarKey := user.AvatarKey
+	var changed []models.Event
+	var cancelled []models.SwapRequest
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if user.TOTPEnabled && !checkTOTP(tx, &user, input.Code) {
+			return errInvalidCode
+		}
+		released, swaps, err := cancelPendingSwaps(tx, user.ID, "requester_id = ? OR receiver_id = ?", user.ID, user.ID)
+		if err != nil {
+			return err
+		}
+		cancelled = swaps
+		if err := tx.Model(&models.ShiftClaim{}).Where("user_id = ? AND status = ?", user.ID, models.ClaimPending).
+			Update("status", models.ClaimWithdrawn).Error; err != nil {
+			return err
+		}
+		freed, err := releaseFutureEvents(tx, user.ID, input.ReassignTo)
+		if err != nil {
+			return err
+		}
+		changed = append(released, freed...)
+		var memberships []models.TeamMembership
+		if err := tx.Where("user_id = ?", user.ID).Find(&memberships).Error; err != nil {
+			return err
+		}
+		for _, m := range memberships {
+			left, _, err := removeMember(tx, m.TeamID, user.ID, user.ID)
+			if err != nil {
+				return err
+			}
+			changed = append(changed, left...)
+		}
+		return anonymizeUser(tx, &user)
+	})
+	if err == errInvalidCode {
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, errLastAdmin) {
+		http.Error(w, "You are the only admin of a team; make someone else an admin first", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	if avatarKey != "" {
+		if err := storage.Default.Delete(r.Context(), avatarKey); err != nil {
+			log.Printf("failed to delete avatar %s: %v", avatarKey, err)
+		}
+	}
+	logAudit(r, "account.deleted", "user", uid, nil)
+	emitCancelledSwaps(r, cancelled)
+	publishSlots(changed...)
+	bus.Default.CloseUser(uid)
+	market.Default.DropUser(uid)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Account deleted"})
+}
+
+// releaseFutureEvents hands the user's upcoming team events to reassignTo
+// where canTakeShift allows it and puts the rest into the open-shift pool.
+// Personal events are deleted. It returns the events as they now are.
+func releaseFutureEvents(tx *gorm.DB, userID uint, reassignTo *uint) ([]models.Event, error) {
+	var events []models.Event
+	if err := tx.Where("user_id = ? AND start_time > ?", userID, time.Now()).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	for i := range events {
+		before := events[i]
+		if events[i].TeamID != nil {
+			allowed := false
+			if reassignTo != nil {
+				var err error
+				if allowed, err = canTakeShift(tx, *reassignTo, events[i]); err != nil {
+					return nil, err
+				}
+			}
+			if !allowed {
+				if err := openShift(tx, &events[i], &userID); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			events[i].UserID = reassignTo
+			events[i].Status = models.SlotBusy
+			if err := saveEvent(tx, before, &events[i], revReassigned, &userID, nil); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := tx.Delete(&events[i]).Error; err != nil {
+			return nil, err
+		}
+		events[i].DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		if err := recordRevision(tx, before, events[i], revDeleted, &userID, nil); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// anonymizeUser strips personal data from the user row and everything that
+// references it, leaving a tombstone that can never log in.
+func anonymizeUser(tx *gorm.DB, user *models.User) error {
+	now := time.Now()
+	oldEmail := auth.NormalizeEmail(user.Email)
+	anonEmail := fmt.Sprintf("deleted-%d@deleted.invalid", user.ID)
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"name":                "Deleted user",
+		"email":               anonEmail,
+		"password":            "!",
+		"email_verified":      false,
+		"email_verified_at":   nil,
+		"pending_email":       "",
+		"totp_secret":         "",
+		"totp_enabled":        false,
+		"avatar_key":          "",
+		"avatar_type":         "",
+		"notify_swap_emails":  false,
+		"notify_shift_emails": false,
+		"notify_daily_digest": false,
+		"anonymized_at":       now,
+	}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.EmailVerification{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.AvailabilityBlock{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.PreferredHours{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.SavedSearch{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.Notification{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.QueuedMail{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.LoginAttempt{}).Where("user_id = ? OR email = ?", user.ID, oldEmail).
+		Updates(map[string]interface{}{"email": anonEmail, "ip": "", "user_agent": ""}).Error
+}
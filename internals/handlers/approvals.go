@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/bus"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+// TeamSettings handles GET and PATCH /api/teams/{id}/settings
+func TeamSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPatch {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	perm := access.ViewTeam
+	if r.Method == http.MethodPatch {
+		perm = access.ManageTeam
+	}
+	if !requireTeamPermission(w, uid, teamID, perm) {
+		return
+	}
+
+	var team models.Team
+	if err := database.DB.First(&team, teamID).Error; err != nil {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPatch {
+		var input struct {
+			Name                *string `json:"name"`
+			RequireSwapApproval *bool   `json:"requireSwapApproval"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		updates := map[string]interface{}{}
+		if input.Name != nil {
+			name := strings.TrimSpace(*input.Name)
+			if name == "" {
+				http.Error(w, "name is required", http.StatusBadRequest)
+				return
+			}
+			updates["name"] = name
+		}
+		if input.RequireSwapApproval != nil {
+			updates["require_swap_approval"] = *input.RequireSwapApproval
+		}
+		if len(updates) > 0 {
+			if err := database.DB.Model(&team).Updates(updates).Error; err != nil {
+				http.Error(w, "Failed to update team", http.StatusInternalServerError)
+				return
+			}
+			logAudit(r, "team.settings_updated", "team", teamID, models.AuditDetails(updates))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(team)
+}
+
+// TeamApprovals handles GET /api/teams/{id}/approvals
+// It lists accepted swaps in the team waiting for a manager's decision.
+func TeamApprovals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+	if !requireTeamPermission(w, uid, teamID, access.ApproveSwaps) {
+		return
+	}
+
+	var swaps []models.SwapRequest
+	if err := database.DB.
+		Where("status = ? AND my_slot_id IN (?)", models.SwapAwaitingApproval,
+			database.DB.Model(&models.Event{}).Select("id").Where("team_id = ?", teamID)).
+		Order("updated_at").
+		Find(&swaps).Error; err != nil {
+		http.Error(w, "Error fetching approvals", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(swaps)
+}
+
+// DecideSwap handles POST /api/swaps/{id}/decision
+// A manager approves an accepted swap, which hands the slots over, or
+// rejects it with a reason, which puts both slots back on the market.
+func DecideSwap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	swapID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid swap ID", http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		Approve bool   `json:"approve"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if !input.Approve && input.Reason == "" {
+		http.Error(w, "reason is required when rejecting", http.StatusBadRequest)
+		return
+	}
+	if len(input.Reason) > 500 {
+		http.Error(w, "reason must be at most 500 characters", http.StatusBadRequest)
+		return
+	}
+
+	var swap models.SwapRequest
+	if err := database.DB.First(&swap, swapID).Error; err != nil {
+		http.Error(w, "Swap request not found", http.StatusNotFound)
+		return
+	}
+
+	var mySlot, theirSlot models.Event
+	if err := database.DB.First(&mySlot, swap.MySlotID).Error; err != nil {
+		http.Error(w, "Slot not found", http.StatusNotFound)
+		return
+	}
+	if err := database.DB.First(&theirSlot, swap.TheirSlotID).Error; err != nil {
+		http.Error(w, "Slot not found", http.StatusNotFound)
+		return
+	}
+	if mySlot.TeamID == nil {
+		http.Error(w, "Swap request not found", http.StatusNotFound)
+		return
+	}
+	if !requireTeamPermission(w, uid, *mySlot.TeamID, access.ApproveSwaps) {
+		return
+	}
+	if uid == swap.RequesterID || uid == swap.ReceiverID {
+		http.Error(w, "You cannot approve your own swap", http.StatusForbidden)
+		return
+	}
+	if swap.Status != models.SwapAwaitingApproval {
+		http.Error(w, "Swap is not awaiting approval", http.StatusConflict)
+		return
+	}
+
+	if input.Approve {
+		if err := checkSwapQualifications(mySlot, theirSlot); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err := checkSwapAvailability(uid, mySlot, theirSlot); err != nil {
+			writeAvailabilityError(w, err)
+			return
+		}
+		if err := checkSwapPolicy(mySlot, theirSlot); err != nil {
+			writePolicyError(w, err)
+			return
+		}
+		// approving overrides warnings, but blocking violations still stand
+		violations, enforcement, err := checkSwapLaborRules(mySlot, theirSlot)
+		if err != nil {
+			http.Error(w, "Failed to check labor rules", http.StatusInternalServerError)
+			return
+		}
+		if len(violations) > 0 && enforcement == models.EnforceBlock {
+			writeViolations(w, violations)
+			return
+		}
+		swap.Warnings = violations
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockSwap(tx, swap, &mySlot, &theirSlot, models.SwapAwaitingApproval); err != nil {
+			return err
+		}
+		swap.ApproverID = &uid
+		if input.Approve {
+			swap.Reason = input.Reason
+			return completeSwap(tx, uid, &swap, &mySlot, &theirSlot)
+		}
+		return releaseSwap(tx, uid, &swap, &mySlot, &theirSlot, models.SwapRejected, input.Reason)
+	})
+	if errors.Is(err, errSwapDecided) || errors.Is(err, errSlotsUnavailable) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to record decision", http.StatusInternalServerError)
+		return
+	}
+	action, outcome := "swap.rejected", bus.SwapRejected
+	if input.Approve {
+		action, outcome = "swap.approved", bus.SwapAccepted
+	}
+	logAudit(r, action, "swap", swap.ID, nil)
+	emitSwap(r, outcome, swap)
+	publishSlots(mySlot, theirSlot)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(swap)
+}
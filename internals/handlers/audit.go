@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/audit"
+	"github.com/jfernsio/slotswapper/internals/auth"
+	"github.com/jfernsio/slotswapper/internals/config"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+const maxAuditPage = 500
+
+// logAudit records an action by the signed-in user on a target. Write
+// failures are logged but never fail a request that has already succeeded.
+func logAudit(r *http.Request, action, targetType string, targetID uint, details models.AuditDetails) {
+	var actor *uint
+	if uid, ok := middleware.UserIDFromContext(r.Context()); ok {
+		actor = &uid
+	}
+	logAuditAs(r, actor, action, targetType, targetID, details)
+}
+
+// logAuditAs is logAudit for requests without a signed-in user, such as
+// signup and login.
+func logAuditAs(r *http.Request, actorID *uint, action, targetType string, targetID uint, details models.AuditDetails) {
+	entry := audit.FromRequest(r, actorID, action)
+	entry.TargetType = targetType
+	if targetType != "" {
+		entry.TargetID = &targetID
+	}
+	entry.Details = details
+	if err := audit.Record(database.DB, &entry); err != nil {
+		log.Printf("failed to write audit entry %s: %v", action, err)
+	}
+}
+
+// requireAuditAdmin writes a 404 and returns false unless the user is
+// listed in AUDIT_ADMIN_EMAILS and has verified that address.
+func requireAuditAdmin(w http.ResponseWriter, uid uint) bool {
+	var user models.User
+	if err := database.DB.Select("email", "email_verified").First(&user, uid).Error; err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return false
+	}
+	// an unverified address may have been registered by someone else
+	if !user.EmailVerified {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return false
+	}
+	email := auth.NormalizeEmail(user.Email)
+	for _, admin := range config.GetAuditAdmins() {
+		if admin == email {
+			return true
+		}
+	}
+	http.Error(w, "Not found", http.StatusNotFound)
+	return false
+}
+
+// parseAuditFilter reads actorId, action, targetType, targetId, from and to
+// (RFC 3339) from the query string.
+func parseAuditFilter(r *http.Request) (audit.Filter, error) {
+	q := r.URL.Query()
+	f := audit.Filter{Action: q.Get("action"), TargetType: q.Get("targetType")}
+	for _, p := range []struct {
+		name string
+		dst  **uint
+	}{{"actorId", &f.ActorID}, {"targetId", &f.TargetID}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return f, errors.New("invalid " + p.name)
+			}
+			id := uint(n)
+			*p.dst = &id
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, errors.New("invalid " + p.name + " format")
+			}
+			*p.dst = &t
+		}
+	}
+	return f, nil
+}
+
+// auditRequest checks the caller is an audit admin and parses the filter,
+// writing the error response on failure.
+func auditRequest(w http.ResponseWriter, r *http.Request) (audit.Filter, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return audit.Filter{}, false
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return audit.Filter{}, false
+	}
+	if !requireAuditAdmin(w, uid) {
+		return audit.Filter{}, false
+	}
+	f, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return f, false
+	}
+	return f, true
+}
+
+// AuditLog handles GET /api/audit
+// Entries come newest first, up to limit (default 100, max 500). Pass the
+// last ID seen as before to page back.
+func AuditLog(w http.ResponseWriter, r *http.Request) {
+	f, ok := auditRequest(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 || limit > maxAuditPage {
+		limit = 100
+	}
+	query := f.Apply(database.DB)
+	if before := q.Get("before"); before != "" {
+		id, err := strconv.ParseUint(before, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid before", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("id < ?", id)
+	}
+
+	var entries []models.AuditEntry
+	if err := query.Order("id DESC").Limit(limit).Find(&entries).Error; err != nil {
+		http.Error(w, "Error fetching audit log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// ExportAuditLog handles GET /api/audit/export
+// It streams every matching entry oldest first, as CSV with format=csv or
+// as JSON lines otherwise. Hashes are included so the export can be checked
+// on its own.
+func ExportAuditLog(w http.ResponseWriter, r *http.Request) {
+	f, ok := auditRequest(w, r)
+	if !ok {
+		return
+	}
+	asCSV := r.URL.Query().Get("format") == "csv"
+
+	var cw *csv.Writer
+	enc := json.NewEncoder(w)
+	stamp := time.Now().UTC().Format("20060102-150405")
+	if asCSV {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit-`+stamp+`.csv"`)
+		cw = csv.NewWriter(w)
+		cw.Write([]string{"id", "createdAt", "actorId", "action", "targetType", "targetId", "ip", "userAgent", "details", "prevHash", "hash"})
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit-`+stamp+`.jsonl"`)
+	}
+
+	optional := func(id *uint) string {
+		if id == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*id), 10)
+	}
+	var batch []models.AuditEntry
+	err := f.Apply(database.DB).Order("id").FindInBatches(&batch, 500, func(_ *gorm.DB, _ int) error {
+		for _, e := range batch {
+			if !asCSV {
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+				continue
+			}
+			details, _ := json.Marshal(e.Details)
+			cw.Write([]string{
+				strconv.FormatUint(uint64(e.ID), 10),
+				e.CreatedAt.UTC().Format(time.RFC3339Nano),
+				optional(e.ActorID),
+				e.Action,
+				e.TargetType,
+				optional(e.TargetID),
+				e.IP,
+				e.UserAgent,
+				string(details),
+				e.PrevHash,
+				e.Hash,
+			})
+		}
+		if cw != nil {
+			cw.Flush()
+			return cw.Error()
+		}
+		return nil
+	}).Error
+	if cw != nil {
+		cw.Flush()
+	}
+	// headers are already sent, so a failure can only cut the export short
+	if err != nil {
+		log.Printf("audit export failed: %v", err)
+	}
+}
+
+// VerifyAuditLog handles GET /api/audit/verify
+// It recomputes the hash chain and reports the first entry that was changed
+// or removed.
+func VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	if _, ok := auditRequest(w, r); !ok {
+		return
+	}
+	res, err := audit.Verify(database.DB)
+	if err != nil {
+		http.Error(w, "Error verifying audit log", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
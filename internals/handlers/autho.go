@@ -2,51 +2,110 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
-	"github.com/jfernsio/slotswapper/internals/database"
-	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/auth"
+	"github.com/jfernsio/slotswapper/internals/middleware"
 	"github.com/jfernsio/slotswapper/internals/utils"
-	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
-func Signup(w http.ResponseWriter, r *http.Request) {
-	var input models.User
+func Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	email := auth.NormalizeEmail(input.Email)
+
+	if rejectIfThrottled(w, r, email, nil) {
+		return
+	}
+
+	// unknown emails and wrong passwords get the same response and take the
+	// same time, so the endpoint can't be used to enumerate accounts
+	user, err := auth.Default.Authenticate(r.Context(), email, input.Password)
+	if errors.Is(err, auth.ErrUnknownUser) {
+		recordLoginAttempt(r, email, nil, false, loginReasonUnknownUser)
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		recordLoginAttempt(r, email, &user.ID, false, loginReasonBadPassword)
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
-	hashed, _ := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
-	input.Password = string(hashed)
 
-	if err := database.DB.Create(&input).Error; err != nil {
-		http.Error(w, "User already exists or invalid data", http.StatusBadRequest)
+	// accounts with 2FA get a challenge token instead; see LoginMFA
+	if user.TOTPEnabled {
+		mfaToken, err := utils.GenerateMFAToken(user.ID)
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"mfaRequired": true, "mfaToken": mfaToken})
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{"message": "Signup successful"})
+	token, err := utils.GenerateToken(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	recordLoginAttempt(r, email, &user.ID, true, "")
+	json.NewEncoder(w).Encode(map[string]string{"token": token})
 }
 
-func Login(w http.ResponseWriter, r *http.Request) {
+// ChangePassword handles POST /api/password
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	var input models.User
-	json.NewDecoder(r.Body).Decode(&input)
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-	var user models.User
-	err := database.DB.Where("email = ?", input.Email).First(&user).Error
-	if err == gorm.ErrRecordNotFound {
-		http.Error(w, "User does not exist!", http.StatusUnauthorized)
+	var input struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)) != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+
+	err := auth.Default.ChangePassword(r.Context(), uid, input.CurrentPassword, input.NewPassword)
+	var verr *auth.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		http.Error(w, verr.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		http.Error(w, "Current password is incorrect", http.StatusUnauthorized)
+		return
+	case errors.Is(err, auth.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "Failed to change password", http.StatusInternalServerError)
 		return
 	}
+	logAudit(r, "auth.password_changed", "user", uid, nil)
 
-	token, _ := utils.GenerateToken(user.ID)
-	json.NewEncoder(w).Encode(map[string]string{"token": token})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password changed"})
 }
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+// unavailableSQL keeps events that don't overlap any availability block of
+// the user (the single bind arg).
+const unavailableSQL = `NOT EXISTS (SELECT 1 FROM availability_blocks ab
+	WHERE ab.user_id = ? AND ab.start_time < events.end_time AND ab.end_time > events.start_time)`
+
+// unavailableDuring returns the user's first block overlapping the range,
+// or nil if they are available.
+func unavailableDuring(db *gorm.DB, userID uint, start, end time.Time) (*models.AvailabilityBlock, error) {
+	var block models.AvailabilityBlock
+	err := db.Where("user_id = ? AND start_time < ? AND end_time > ?", userID, end, start).
+		Order("start_time").First(&block).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// availabilityError says a user can't take a slot.
+type availabilityError struct{ msg string }
+
+func (e *availabilityError) Error() string { return e.msg }
+
+// availabilityConflict returns an *availabilityError if the user can't take
+// the slot. Only the user themselves is told whether it is leave, so
+// coworkers can't learn who is on leave.
+func availabilityConflict(viewer, userID uint, slot models.Event) error {
+	block, err := unavailableDuring(database.DB, userID, slot.StartTime, slot.EndTime)
+	if err != nil || block == nil {
+		return err
+	}
+	if viewer != userID {
+		return &availabilityError{fmt.Sprintf("user %d is not available during %q", userID, slot.Title)}
+	}
+	kind := "unavailable"
+	if block.Kind == models.AvailabilityLeave {
+		kind = "on leave"
+	}
+	return &availabilityError{fmt.Sprintf("you are %s during %q", kind, slot.Title)}
+}
+
+// checkSwapAvailability makes sure neither side of a swap would end up with
+// a slot during their leave. A party to the swap only hears that the other
+// user can't take their slot.
+func checkSwapAvailability(viewer uint, mySlot, theirSlot models.Event) error {
+	sides := []struct {
+		userID uint
+		slot   models.Event
+	}{{mySlot.OwnerID(), theirSlot}, {theirSlot.OwnerID(), mySlot}}
+	party := viewer == mySlot.OwnerID() || viewer == theirSlot.OwnerID()
+	for _, s := range sides {
+		err := availabilityConflict(viewer, s.userID, s.slot)
+		var aerr *availabilityError
+		if party && s.userID != viewer && errors.As(err, &aerr) {
+			return &availabilityError{"the other user can't take this slot"}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAvailabilityError maps an availability check result to a response.
+func writeAvailabilityError(w http.ResponseWriter, err error) {
+	var aerr *availabilityError
+	if errors.As(err, &aerr) {
+		http.Error(w, aerr.Error(), http.StatusConflict)
+		return
+	}
+	http.Error(w, "db error", http.StatusInternalServerError)
+}
+
+// withinPreferredHours reports whether the slot fits in one of the windows,
+// read in loc. Users without preferred hours accept any time.
+func withinPreferredHours(hours []models.PreferredHours, loc *time.Location, start, end time.Time) bool {
+	if len(hours) == 0 {
+		return true
+	}
+	start, end = start.In(loc), end.In(loc)
+	for _, h := range hours {
+		if int(start.Weekday()) != h.Weekday {
+			continue
+		}
+		from, err1 := time.ParseInLocation("15:04", h.StartTime, loc)
+		to, err2 := time.ParseInLocation("15:04", h.EndTime, loc)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		winStart := time.Date(start.Year(), start.Month(), start.Day(), from.Hour(), from.Minute(), 0, 0, loc)
+		winEnd := time.Date(start.Year(), start.Month(), start.Day(), to.Hour(), to.Minute(), 0, 0, loc)
+		if !start.Before(winStart) && !end.After(winEnd) {
+			return true
+		}
+	}
+	return false
+}
+
+// userLocation returns the user's configured time zone, falling back to UTC.
+func userLocation(user models.User) *time.Location {
+	if loc, err := time.LoadLocation(user.TimeZone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// AvailabilityBlocks handles GET and POST /api/availability/blocks
+// GET lists the caller's blocks that haven't ended. POST adds one and
+// reports the caller's events it overlaps so they can trade them away.
+func AvailabilityBlocks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		var blocks []models.AvailabilityBlock
+		if err := database.DB.Where("user_id = ? AND end_time > ?", uid, time.Now()).
+			Order("start_time").Find(&blocks).Error; err != nil {
+			http.Error(w, "Error fetching availability", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(blocks)
+		return
+	}
+
+	var input struct {
+		Kind      models.AvailabilityKind `json:"kind"`
+		StartTime string                  `json:"startTime"`
+		EndTime   string                  `json:"endTime"`
+		Note      string                  `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if input.Kind == "" {
+		input.Kind = models.AvailabilityUnavailable
+	}
+	if input.Kind != models.AvailabilityLeave && input.Kind != models.AvailabilityUnavailable {
+		http.Error(w, "kind must be leave or unavailable", http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse(time.RFC3339, input.StartTime)
+	if err != nil {
+		http.Error(w, "Invalid startTime format", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse(time.RFC3339, input.EndTime)
+	if err != nil {
+		http.Error(w, "Invalid endTime format", http.StatusBadRequest)
+		return
+	}
+	if !end.After(start) {
+		http.Error(w, "endTime must be after startTime", http.StatusBadRequest)
+		return
+	}
+	input.Note = strings.TrimSpace(input.Note)
+	if len(input.Note) > 200 {
+		http.Error(w, "note must be at most 200 characters", http.StatusBadRequest)
+		return
+	}
+
+	block := models.AvailabilityBlock{
+		UserID:    uid,
+		Kind:      input.Kind,
+		StartTime: start,
+		EndTime:   end,
+		Note:      input.Note,
+	}
+	if err := database.DB.Create(&block).Error; err != nil {
+		http.Error(w, "Failed to save availability", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "availability.block_created", "availability_block", block.ID, models.AuditDetails{"kind": block.Kind})
+
+	var conflicts []models.Event
+	if err := database.DB.Where("user_id = ? AND start_time < ? AND end_time > ?", uid, end, start).
+		Order("start_time").Find(&conflicts).Error; err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"block":     block,
+		"conflicts": conflicts,
+	})
+}
+
+// DeleteAvailabilityBlock handles DELETE /api/availability/blocks/{id}
+func DeleteAvailabilityBlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	blockID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid block ID", http.StatusBadRequest)
+		return
+	}
+
+	res := database.DB.Where("id = ? AND user_id = ?", blockID, uid).Delete(&models.AvailabilityBlock{})
+	if res.Error != nil {
+		http.Error(w, "Failed to delete availability", http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		http.Error(w, "Block not found", http.StatusNotFound)
+		return
+	}
+	logAudit(r, "availability.block_deleted", "availability_block", blockID, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Block deleted"})
+}
+
+// PreferredHoursHandler handles GET and PUT /api/availability/preferred-hours
+// PUT replaces the whole weekly set.
+func PreferredHoursHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var input []models.PreferredHours
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		for i := range input {
+			h := &input[i]
+			if h.Weekday < 0 || h.Weekday > 6 {
+				http.Error(w, "weekday must be between 0 (Sunday) and 6", http.StatusBadRequest)
+				return
+			}
+			from, err1 := time.Parse("15:04", h.StartTime)
+			to, err2 := time.Parse("15:04", h.EndTime)
+			if err1 != nil || err2 != nil {
+				http.Error(w, "startTime and endTime must be HH:MM", http.StatusBadRequest)
+				return
+			}
+			if !to.After(from) {
+				http.Error(w, "endTime must be after startTime", http.StatusBadRequest)
+				return
+			}
+			h.ID = 0
+			h.UserID = uid
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("user_id = ?", uid).Delete(&models.PreferredHours{}).Error; err != nil {
+				return err
+			}
+			if len(input) == 0 {
+				return nil
+			}
+			return tx.Create(&input).Error
+		})
+		if err != nil {
+			http.Error(w, "Failed to save preferred hours", http.StatusInternalServerError)
+			return
+		}
+		logAudit(r, "availability.preferred_hours_updated", "user", uid, nil)
+	}
+
+	hours := []models.PreferredHours{}
+	if err := database.DB.Where("user_id = ?", uid).Order("weekday, start_time").Find(&hours).Error; err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hours)
+}
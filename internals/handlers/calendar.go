@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+const (
+	maxCalendarDays       = 400
+	maxCalendarEventsDays = 42
+)
+
+// CalendarBucket is one day, week or month of a calendar view.
+type CalendarBucket struct {
+	Start  time.Time                 `json:"start"`
+	End    time.Time                 `json:"end"`
+	Label  string                    `json:"label"`
+	Total  int                       `json:"total"`
+	Counts map[models.SlotStatus]int `json:"counts"`
+	Events []models.Event            `json:"events,omitempty"`
+}
+
+// bucketStart truncates t, read in loc, to the start of its bucket. Weeks
+// start on Monday.
+func bucketStart(t time.Time, groupBy string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	switch groupBy {
+	case "week":
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	default:
+		return day
+	}
+}
+
+func nextBucket(start time.Time, groupBy string) time.Time {
+	switch groupBy {
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+func bucketLabel(start time.Time, groupBy string) string {
+	switch groupBy {
+	case "week":
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case "month":
+		return start.Format("2006-01")
+	default:
+		return start.Format("2006-01-02")
+	}
+}
+
+// CalendarView handles GET /api/calendar
+// Query: from and to (YYYY-MM-DD, to inclusive), groupBy=day|week|month,
+// tz (defaults to the caller's time zone), teamId for a team roster instead
+// of the caller's own events, and events=true to include the events in
+// each bucket for ranges up to six weeks. Events are bucketed by their
+// start time.
+func CalendarView(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	q := r.URL.Query()
+
+	groupBy := q.Get("groupBy")
+	if groupBy == "" {
+		groupBy = "day"
+	}
+	if groupBy != "day" && groupBy != "week" && groupBy != "month" {
+		http.Error(w, "groupBy must be day, week or month", http.StatusBadRequest)
+		return
+	}
+
+	tz := q.Get("tz")
+	if tz == "" {
+		var user models.User
+		if err := database.DB.Select("time_zone").First(&user, uid).Error; err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		tz = user.TimeZone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		http.Error(w, "Invalid time zone", http.StatusBadRequest)
+		return
+	}
+
+	from, err := time.ParseInLocation("2006-01-02", q.Get("from"), loc)
+	if err != nil {
+		http.Error(w, "Invalid from date, use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	to, err := time.ParseInLocation("2006-01-02", q.Get("to"), loc)
+	if err != nil {
+		http.Error(w, "Invalid to date, use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+	// widen to whole buckets so the first and last ones aren't partial
+	rangeStart := bucketStart(from, groupBy, loc)
+	rangeEnd := nextBucket(bucketStart(to, groupBy, loc), groupBy)
+	days := int(rangeEnd.Sub(rangeStart).Hours() / 24)
+	if days > maxCalendarDays {
+		http.Error(w, "range is too long", http.StatusBadRequest)
+		return
+	}
+	withEvents := q.Get("events") == "true"
+	if withEvents && days > maxCalendarEventsDays {
+		http.Error(w, "events can only be included for ranges up to six weeks", http.StatusBadRequest)
+		return
+	}
+
+	scope := func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ?", uid)
+	}
+	if teamParam := q.Get("teamId"); teamParam != "" {
+		teamID, err := strconv.ParseUint(teamParam, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid team ID", http.StatusBadRequest)
+			return
+		}
+		if !requireTeamPermission(w, uid, uint(teamID), access.ViewTeam) {
+			return
+		}
+		scope = func(db *gorm.DB) *gorm.DB {
+			return db.Where("team_id = ?", teamID)
+		}
+	}
+
+	// the database does the bucketing, so only the counts come back
+	var rows []struct {
+		Bucket time.Time
+		Status models.SlotStatus
+		N      int
+	}
+	if err := database.DB.Model(&models.Event{}).Scopes(scope).
+		Select("date_trunc(?, start_time AT TIME ZONE ?) AS bucket, status, COUNT(*) AS n", groupBy, loc.String()).
+		Where("start_time >= ? AND start_time < ?", rangeStart, rangeEnd).
+		Group("bucket, status").
+		Scan(&rows).Error; err != nil {
+		http.Error(w, "Error building calendar", http.StatusInternalServerError)
+		return
+	}
+
+	buckets := []CalendarBucket{}
+	index := map[string]int{}
+	for start := rangeStart; start.Before(rangeEnd); start = nextBucket(start, groupBy) {
+		label := bucketLabel(start, groupBy)
+		index[label] = len(buckets)
+		buckets = append(buckets, CalendarBucket{
+			Start:  start,
+			End:    nextBucket(start, groupBy),
+			Label:  label,
+			Counts: map[models.SlotStatus]int{},
+		})
+	}
+	for _, row := range rows {
+		// the truncated timestamp is wall-clock time in loc
+		local := time.Date(row.Bucket.Year(), row.Bucket.Month(), row.Bucket.Day(), 0, 0, 0, 0, loc)
+		if i, ok := index[bucketLabel(local, groupBy)]; ok {
+			buckets[i].Counts[row.Status] += row.N
+			buckets[i].Total += row.N
+		}
+	}
+
+	if withEvents {
+		var events []models.Event
+		if err := database.DB.Scopes(scope).
+			Where("start_time >= ? AND start_time < ?", rangeStart, rangeEnd).
+			Order("start_time").Find(&events).Error; err != nil {
+			http.Error(w, "Error building calendar", http.StatusInternalServerError)
+			return
+		}
+		for _, e := range events {
+			if i, ok := index[bucketLabel(bucketStart(e.StartTime, groupBy, loc), groupBy)]; ok {
+				buckets[i].Events = append(buckets[i].Events, e)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"timeZone": loc.String(),
+		"groupBy":  groupBy,
+		"buckets":  buckets,
+	})
+}
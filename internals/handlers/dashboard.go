@@ -8,29 +8,6 @@ import (
 	"github.com/jfernsio/slotswapper/internals/models"
 )
 
-// func Profile(w http.ResponseWriter, r *http.Request) {
-// 	if r.Method != http.MethodGet {
-// 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-// 		return
-// 	}
-// 	userID := r.Context().Value("user_id")
-// 	if userID == nil {
-// 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-// 		return
-// 	}
-
-// 	var user models.User
-// 	if err := database.DB.First(&user, userID).Error; err != nil {
-// 		http.Error(w, "User not found", http.StatusNotFound)
-// 		return
-// 	}
-
-
-
-// 	w.Header().Set("Content-Type", "application/json")
-// 	json.NewEncoder(w).Encode(response)
-// }
-
 func Dashboard(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id")
 	if userID == nil {
@@ -48,6 +25,7 @@ func Dashboard(w http.ResponseWriter, r *http.Request) {
 	userRes := map[string]interface{}{
 		"id":    user.ID,
 		"name":  user.Name,
+		"emailVerified": user.EmailVerified,
 	}
 
 	// get events for this user
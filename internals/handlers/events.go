@@ -7,8 +7,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jfernsio/slotswapper/internals/access"
 	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
 	"github.com/jfernsio/slotswapper/internals/models"
+	"gorm.io/gorm"
 )
 
 func isValidSlotStatus(s string) bool {
@@ -19,6 +22,19 @@ func isValidSlotStatus(s string) bool {
         return false
     }
 }
+
+// canEditEvent allows the owner, or a manager of the event's team, to change it.
+func canEditEvent(uid uint, event models.Event) bool {
+	if event.OwnedBy(uid) {
+		return true
+	}
+	if event.TeamID == nil {
+		return false
+	}
+	allowed, err := access.Can(uid, *event.TeamID, access.ManageEvents)
+	return err == nil && allowed
+}
+
 func CreateEvent(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -44,6 +60,10 @@ func CreateEvent(w http.ResponseWriter, r *http.Request) {
 		StartTime string `json:"startTime"` // ISO string
 		EndTime   string `json:"endTime"`
 		Status    string `json:"status"`
+		TeamID    *uint  `json:"teamId"`
+		// UserID lets a team manager create the event for another member
+		UserID *uint `json:"userId"`
+		RequiredQualifications []string `json:"requiredQualifications"`
 	}
 
 	var input EventInput
@@ -77,18 +97,79 @@ func CreateEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// team events are only visible to, and swappable within, that team
+	owner := uid
+	if input.UserID != nil && *input.UserID != uid {
+		if input.TeamID == nil {
+			http.Error(w, "teamId is required when creating an event for someone else", http.StatusBadRequest)
+			return
+		}
+		if !requireTeamPermission(w, uid, *input.TeamID, access.ManageEvents) {
+			return
+		}
+		member, err := isTeamMember(database.DB, *input.UserID, *input.TeamID)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if !member {
+			http.Error(w, "userId is not a member of this team", http.StatusBadRequest)
+			return
+		}
+		owner = *input.UserID
+	} else if input.TeamID != nil && !requireTeamPermission(w, uid, *input.TeamID, access.CreateOwnEvent) {
+		return
+	}
+
+	required, err := normalizeQualifications(input.RequiredQualifications)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(required) > 0 && input.TeamID == nil {
+		http.Error(w, "Only team events can require qualifications", http.StatusBadRequest)
+		return
+	}
+
 	event := models.Event{
 		Title:     input.Title,
 		StartTime: start,
 		EndTime:   end,
 		Status:    status, 
-		UserID:    uid,
+		UserID:    &owner,
+		TeamID:    input.TeamID,
+		RequiredQualifications: required,
+	}
+
+	if missing, err := missingQualifications(database.DB, owner, event); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	} else if len(missing) > 0 {
+		http.Error(w, "Owner lacks qualifications: "+strings.Join(missing, ", "), http.StatusBadRequest)
+		return
+	}
+	// managers can't put work on someone's leave; owners may still log their own
+	if owner != uid {
+		if err := availabilityConflict(uid, owner, event); err != nil {
+			writeAvailabilityError(w, err)
+			return
+		}
 	}
 
-	if err := database.DB.Create(&event).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		return recordCreation(tx, event, revCreated, &uid)
+	})
+	if err != nil {
 		http.Error(w, "Failed to create event", http.StatusInternalServerError)
 		return
 	}
+	logAudit(r, "event.created", "event", event.ID, models.AuditDetails{"userId": owner})
+	emitEventChanged(r, event, "created")
+	publishSlots(event)
+	notifySavedSearches(r, event)
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(event)
@@ -158,19 +239,31 @@ func UpdateEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	uid, _ := middleware.UserIDFromContext(r.Context())
 	var event models.Event
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&event).Error; err != nil {
+	if err := database.DB.First(&event, id).Error; err != nil || !canEditEvent(uid, event) {
 		http.Error(w, "Event not found", http.StatusNotFound)
 		return
 	}
+	if isOpenShift(event) {
+		http.Error(w, "Open shifts are assigned by claiming or reassigning them", http.StatusConflict)
+		return
+	}
 
+	before := event
 	event.Status = models.SlotStatus(input.Status)
 	event.UpdatedAt = time.Now()
 
-	if err := database.DB.Save(&event).Error; err != nil {
+	if err := saveEvent(database.DB, before, &event, revUpdated, &uid, nil); err != nil {
 		http.Error(w, "Failed to update event", http.StatusInternalServerError)
 		return
 	}
+	logAudit(r, "event.updated", "event", event.ID, models.AuditDetails{"status": event.Status})
+	emitEventChanged(r, event, "updated")
+	publishSlots(event)
+	if before.Status != models.SlotSwappable {
+		notifySavedSearches(r, event)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(event)
@@ -204,16 +297,39 @@ func DeletEvent(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+	uid, _ := middleware.UserIDFromContext(r.Context())
 	var event models.Event
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&event).Error; err != nil {
+	if err := database.DB.First(&event, id).Error; err != nil || !canEditEvent(uid, event) {
 		http.Error(w, "Event not found", http.StatusNotFound)
 		return
 	}
-	
-	if err := database.DB.Delete(&event).Error; err != nil {
+
+	// the event goes to the trash; swaps and claims on it can't go ahead
+	var released []models.Event
+	var cancelled []models.SwapRequest
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if released, cancelled, err = cancelPendingSwaps(tx, uid, "my_slot_id = ? OR their_slot_id = ?", event.ID, event.ID); err != nil {
+			return err
+		}
+		if _, err := declinePendingClaims(tx, event.ID); err != nil {
+			return err
+		}
+		before := event
+		if err := tx.Delete(&event).Error; err != nil {
+			return err
+		}
+		event.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		return recordRevision(tx, before, event, revDeleted, &uid, nil)
+	})
+	if err != nil {
 		http.Error(w, "Failed to delete event", http.StatusInternalServerError)
 		return
 	}
+	logAudit(r, "event.deleted", "event", event.ID, nil)
+	emitEventChanged(r, event, "deleted")
+	emitCancelledSwaps(r, cancelled)
+	publishSlots(append(released, event)...)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode("Event deleted successfully")
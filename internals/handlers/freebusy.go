@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/freebusy"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+const (
+	maxFreeBusyUsers  = 50
+	maxFreeBusyWindow = 31 * 24 * time.Hour
+	maxProposals      = 50
+)
+
+var errNotVisible = errors.New("you can only query yourself and members of your teams")
+
+// freeBusyQuery is the body shared by the free/busy endpoints.
+type freeBusyQuery struct {
+	UserIDs []uint `json:"userIds"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+// parse validates the query and returns the de-duplicated users and window.
+func (q freeBusyQuery) parse() ([]uint, freebusy.Interval, error) {
+	var window freebusy.Interval
+	start, err := time.Parse(time.RFC3339, q.Start)
+	if err != nil {
+		return nil, window, errors.New("invalid start format")
+	}
+	end, err := time.Parse(time.RFC3339, q.End)
+	if err != nil {
+		return nil, window, errors.New("invalid end format")
+	}
+	if !end.After(start) {
+		return nil, window, errors.New("end must be after start")
+	}
+	if end.Sub(start) > maxFreeBusyWindow {
+		return nil, window, errors.New("window must be at most 31 days")
+	}
+
+	seen := map[uint]bool{}
+	users := make([]uint, 0, len(q.UserIDs))
+	for _, id := range q.UserIDs {
+		if !seen[id] {
+			seen[id] = true
+			users = append(users, id)
+		}
+	}
+	if len(users) == 0 || len(users) > maxFreeBusyUsers {
+		return nil, window, errors.New("userIds must list between 1 and 50 users")
+	}
+	return users, freebusy.Interval{Start: start, End: end}, nil
+}
+
+// checkUsersVisible allows the caller to query themselves and anyone they
+// share a team with.
+func checkUsersVisible(uid uint, users []uint) error {
+	teamIDs, err := teamIDsForUser(uid)
+	if err != nil {
+		return err
+	}
+	visible := map[uint]bool{uid: true}
+	if len(teamIDs) > 0 {
+		var ids []uint
+		if err := database.DB.Model(&models.TeamMembership{}).
+			Where("team_id IN ?", teamIDs).Distinct().Pluck("user_id", &ids).Error; err != nil {
+			return err
+		}
+		for _, id := range ids {
+			visible[id] = true
+		}
+	}
+	for _, id := range users {
+		if !visible[id] {
+			return errNotVisible
+		}
+	}
+	return nil
+}
+
+// loadBusy returns each user's merged busy time in the window: their events
+// plus any leave or unavailability.
+func loadBusy(users []uint, window freebusy.Interval) (map[uint][]freebusy.Interval, error) {
+	raw := make(map[uint][]freebusy.Interval, len(users))
+	for _, id := range users {
+		raw[id] = nil
+	}
+
+	var events []models.Event
+	if err := database.DB.Select("user_id", "start_time", "end_time").
+		Where("user_id IN ? AND start_time < ? AND end_time > ?", users, window.End, window.Start).
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		raw[e.OwnerID()] = append(raw[e.OwnerID()], freebusy.Interval{Start: e.StartTime, End: e.EndTime})
+	}
+
+	var blocks []models.AvailabilityBlock
+	if err := database.DB.Select("user_id", "start_time", "end_time").
+		Where("user_id IN ? AND start_time < ? AND end_time > ?", users, window.End, window.Start).
+		Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		raw[b.UserID] = append(raw[b.UserID], freebusy.Interval{Start: b.StartTime, End: b.EndTime})
+	}
+
+	busy := make(map[uint][]freebusy.Interval, len(raw))
+	for id, intervals := range raw {
+		busy[id] = freebusy.Merge(intervals, window)
+	}
+	return busy, nil
+}
+
+// decodeFreeBusy reads the body into dst and resolves the users and window,
+// writing the error response on failure.
+func decodeFreeBusy(w http.ResponseWriter, r *http.Request, dst interface{}, q *freeBusyQuery) ([]uint, freebusy.Interval, bool) {
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, freebusy.Interval{}, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, freebusy.Interval{}, false
+	}
+	users, window, err := q.parse()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, window, false
+	}
+	if err := checkUsersVisible(uid, users); errors.Is(err, errNotVisible) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil, window, false
+	} else if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return nil, window, false
+	}
+	return users, window, true
+}
+
+// FreeBusy handles POST /api/freebusy
+// It returns merged busy intervals per user, without event details.
+func FreeBusy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var input freeBusyQuery
+	users, window, ok := decodeFreeBusy(w, r, &input, &input)
+	if !ok {
+		return
+	}
+
+	busy, err := loadBusy(users, window)
+	if err != nil {
+		http.Error(w, "Error fetching free/busy", http.StatusInternalServerError)
+		return
+	}
+
+	type userBusy struct {
+		UserID uint                `json:"userId"`
+		Busy   []freebusy.Interval `json:"busy"`
+	}
+	out := make([]userBusy, 0, len(users))
+	for _, id := range users {
+		out = append(out, userBusy{UserID: id, Busy: busy[id]})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"start": window.Start,
+		"end":   window.End,
+		"users": out,
+	})
+}
+
+// FindAvailability handles POST /api/freebusy/find
+// It proposes slots of durationMinutes where at least quorum of the users
+// (all of them by default) are free.
+func FindAvailability(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var input struct {
+		freeBusyQuery
+		DurationMinutes int `json:"durationMinutes"`
+		StepMinutes     int `json:"stepMinutes"`
+		Quorum          int `json:"quorum"`
+		Limit           int `json:"limit"`
+	}
+	users, window, ok := decodeFreeBusy(w, r, &input, &input.freeBusyQuery)
+	if !ok {
+		return
+	}
+
+	if input.DurationMinutes < 5 || input.DurationMinutes > 24*60 {
+		http.Error(w, "durationMinutes must be between 5 and 1440", http.StatusBadRequest)
+		return
+	}
+	if input.StepMinutes == 0 {
+		input.StepMinutes = 15
+	}
+	if input.StepMinutes < 5 || input.StepMinutes > 24*60 {
+		http.Error(w, "stepMinutes must be between 5 and 1440", http.StatusBadRequest)
+		return
+	}
+	if input.Quorum == 0 {
+		input.Quorum = len(users)
+	}
+	if input.Quorum < 1 || input.Quorum > len(users) {
+		http.Error(w, "quorum must be between 1 and the number of users", http.StatusBadRequest)
+		return
+	}
+	if input.Limit <= 0 || input.Limit > maxProposals {
+		input.Limit = 10
+	}
+
+	busy, err := loadBusy(users, window)
+	if err != nil {
+		http.Error(w, "Error fetching free/busy", http.StatusInternalServerError)
+		return
+	}
+	proposals := freebusy.Find(busy, window,
+		time.Duration(input.DurationMinutes)*time.Minute,
+		time.Duration(input.StepMinutes)*time.Minute,
+		input.Quorum, input.Limit)
+	if proposals == nil {
+		proposals = []freebusy.Proposal{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proposals)
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/rules"
+)
+
+// laborEngine builds the rule engine configured for a team. A nil engine
+// means no rules apply.
+func laborEngine(db *gorm.DB, teamID *uint) (*rules.Engine, models.LaborEnforcement, error) {
+	if teamID == nil {
+		return nil, "", nil
+	}
+	var cfg models.TeamLaborRules
+	err := db.Where("team_id = ?", *teamID).First(&cfg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	engine := rules.Engine{Severity: rules.SeverityError}
+	if cfg.Enforcement == models.EnforceWarn {
+		engine.Severity = rules.SeverityWarning
+	}
+	if cfg.MaxHoursPerWeek > 0 {
+		engine.Rules = append(engine.Rules, rules.MaxHoursPerWeek{Hours: cfg.MaxHoursPerWeek})
+	}
+	if cfg.MinRestHours > 0 {
+		engine.Rules = append(engine.Rules, rules.MinRestBetweenShifts{Hours: cfg.MinRestHours})
+	}
+	if cfg.MaxConsecutiveDays > 0 {
+		engine.Rules = append(engine.Rules, rules.MaxConsecutiveDays{Days: cfg.MaxConsecutiveDays})
+	}
+	if len(engine.Rules) == 0 {
+		return nil, "", nil
+	}
+	return &engine, cfg.Enforcement, nil
+}
+
+// scheduleAround loads the user's shifts near the given slot, leaving out
+// the event with ID skip.
+func scheduleAround(db *gorm.DB, userID uint, near models.Event, skip uint, window time.Duration) ([]rules.Shift, error) {
+	var events []models.Event
+	if err := db.
+		Where("user_id = ? AND id <> ? AND end_time > ? AND start_time < ?",
+			userID, skip, near.StartTime.Add(-window), near.EndTime.Add(window)).
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	shifts := make([]rules.Shift, 0, len(events)+1)
+	for _, e := range events {
+		shifts = append(shifts, rules.Shift{EventID: e.ID, Start: e.StartTime, End: e.EndTime})
+	}
+	return shifts, nil
+}
+
+// newViolations evaluates a user's schedule before and after giving up lose
+// and taking gain, and returns only the violations the change introduces or
+// makes worse. A limit the schedule already breaks doesn't hold up a change
+// that leaves it no worse.
+func newViolations(db *gorm.DB, engine *rules.Engine, userID uint, lose, gain models.Event) ([]rules.Violation, error) {
+	window := engine.Lookback()
+	before, err := scheduleAround(db, userID, gain, 0, window)
+	if err != nil {
+		return nil, err
+	}
+	after, err := scheduleAround(db, userID, gain, lose.ID, window)
+	if err != nil {
+		return nil, err
+	}
+	after = append(after, rules.Shift{EventID: gain.ID, Start: gain.StartTime, End: gain.EndTime})
+
+	existing := engine.Evaluate(userID, before)
+	var out []rules.Violation
+	for _, v := range engine.Evaluate(userID, after) {
+		if !slices.ContainsFunc(existing, func(e rules.Violation) bool { return e.Covers(v) }) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// checkSwapLaborRules evaluates both parties' schedules as they would be
+// after the swap, using the rules of the slots' team.
+func checkSwapLaborRules(mySlot, theirSlot models.Event) ([]rules.Violation, models.LaborEnforcement, error) {
+	engine, enforcement, err := laborEngine(database.DB, mySlot.TeamID)
+	if err != nil || engine == nil {
+		return nil, "", err
+	}
+	requester, err := newViolations(database.DB, engine, mySlot.OwnerID(), mySlot, theirSlot)
+	if err != nil {
+		return nil, "", err
+	}
+	receiver, err := newViolations(database.DB, engine, theirSlot.OwnerID(), theirSlot, mySlot)
+	if err != nil {
+		return nil, "", err
+	}
+	return append(requester, receiver...), enforcement, nil
+}
+
+// writeViolations responds with the structured list of broken rules.
+func writeViolations(w http.ResponseWriter, violations []rules.Violation) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      "swap breaks labor rules",
+		"violations": violations,
+	})
+}
+
+// LaborRules handles GET and PUT /api/teams/{id}/labor-rules
+// maxHoursPerWeek counts ISO weeks and maxConsecutiveDays counts calendar
+// days, both in UTC.
+func LaborRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	perm := access.ViewTeam
+	if r.Method == http.MethodPut {
+		perm = access.ManageTeam
+	}
+	if !requireTeamPermission(w, uid, teamID, perm) {
+		return
+	}
+
+	cfg := models.TeamLaborRules{TeamID: teamID, Enforcement: models.EnforceBlock}
+	if err := database.DB.Where("team_id = ?", teamID).First(&cfg).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var input struct {
+			MaxHoursPerWeek    float64                 `json:"maxHoursPerWeek"`
+			MinRestHours       float64                 `json:"minRestHours"`
+			MaxConsecutiveDays int                     `json:"maxConsecutiveDays"`
+			Enforcement        models.LaborEnforcement `json:"enforcement"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if input.MaxHoursPerWeek < 0 || input.MinRestHours < 0 || input.MaxConsecutiveDays < 0 {
+			http.Error(w, "limits must not be negative", http.StatusBadRequest)
+			return
+		}
+		if input.Enforcement == "" {
+			input.Enforcement = models.EnforceBlock
+		}
+		if input.Enforcement != models.EnforceBlock && input.Enforcement != models.EnforceWarn {
+			http.Error(w, "enforcement must be block or warn", http.StatusBadRequest)
+			return
+		}
+
+		cfg.MaxHoursPerWeek = input.MaxHoursPerWeek
+		cfg.MinRestHours = input.MinRestHours
+		cfg.MaxConsecutiveDays = input.MaxConsecutiveDays
+		cfg.Enforcement = input.Enforcement
+		if err := database.DB.Save(&cfg).Error; err != nil {
+			http.Error(w, "Failed to save labor rules", http.StatusInternalServerError)
+			return
+		}
+		logAudit(r, "team.labor_rules_updated", "team", teamID, models.AuditDetails{
+			"maxHoursPerWeek":    cfg.MaxHoursPerWeek,
+			"minRestHours":       cfg.MinRestHours,
+			"maxConsecutiveDays": cfg.MaxConsecutiveDays,
+			"enforcement":        cfg.Enforcement,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cfg)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jfernsio/slotswapper/internals/config"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/utils"
+)
+
+// Reasons stored on failed LoginAttempt rows.
+const (
+	loginReasonUnknownUser = "unknown_user"
+	loginReasonBadPassword = "bad_password"
+	loginReasonBadMFA      = "bad_mfa_code"
+	loginReasonLocked      = "locked"
+)
+
+// lockoutFor returns how long to block after n failures. Below the
+// threshold there is no delay; past it the delay doubles per failure.
+func lockoutFor(n int64, threshold int, t config.LoginThrottle) time.Duration {
+	if threshold <= 0 || n < int64(threshold) {
+		return 0
+	}
+	exp := float64(n - int64(threshold))
+	d := time.Duration(float64(t.BaseLockout) * math.Pow(2, exp))
+	if d <= 0 || d > t.MaxLockout {
+		return t.MaxLockout
+	}
+	return d
+}
+
+// loginRetryAfter reports how long the email/IP pair must wait before it may
+// try again. Zero means the attempt is allowed.
+func loginRetryAfter(email, ip string) (time.Duration, error) {
+	t := config.GetLoginThrottle()
+	now := time.Now()
+	since := now.Add(-t.Window)
+
+	// a successful login resets the per-account counter
+	accountSince := since
+	var lastSuccess models.LoginAttempt
+	if err := database.DB.Where("email = ? AND success = ? AND created_at > ?", email, true, since).
+		Order("created_at desc").First(&lastSuccess).Error; err == nil {
+		accountSince = lastSuccess.CreatedAt
+	}
+
+	wait := time.Duration(0)
+	checks := []struct {
+		column    string
+		value     string
+		since     time.Time
+		threshold int
+	}{
+		{"email", email, accountSince, t.MaxAccountFailures},
+		{"ip", ip, since, t.MaxIPFailures},
+	}
+	for _, c := range checks {
+		// rejected attempts while locked are logged but don't extend the lockout
+		var failures int64
+		if err := database.DB.Model(&models.LoginAttempt{}).
+			Where(c.column+" = ? AND success = ? AND reason <> ? AND created_at > ?", c.value, false, loginReasonLocked, c.since).
+			Count(&failures).Error; err != nil {
+			return 0, err
+		}
+		lock := lockoutFor(failures, c.threshold, t)
+		if lock == 0 {
+			continue
+		}
+		var last models.LoginAttempt
+		if err := database.DB.Where(c.column+" = ? AND success = ? AND reason <> ?", c.value, false, loginReasonLocked).
+			Order("created_at desc").First(&last).Error; err != nil {
+			return 0, err
+		}
+		if remaining := last.CreatedAt.Add(lock).Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+	return wait, nil
+}
+
+// recordLoginAttempt stores the outcome of a login try. Failures to write
+// are logged but never block the request.
+func recordLoginAttempt(r *http.Request, email string, userID *uint, success bool, reason string) {
+	attempt := models.LoginAttempt{
+		Email:     email,
+		UserID:    userID,
+		IP:        utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   success,
+		Reason:    reason,
+	}
+	if len(attempt.UserAgent) > 300 {
+		attempt.UserAgent = attempt.UserAgent[:300]
+	}
+	if err := database.DB.Create(&attempt).Error; err != nil {
+		log.Printf("failed to record login attempt for %s: %v", email, err)
+	}
+
+	// the email stays out of the audit log; the attempt row links the two
+	action, details := "auth.login", models.AuditDetails{"attemptId": attempt.ID}
+	if !success {
+		action, details["reason"] = "auth.login_failed", reason
+	}
+	if userID == nil {
+		logAuditAs(r, nil, action, "", 0, details)
+		return
+	}
+	logAuditAs(r, userID, action, "user", *userID, details)
+}
+
+// rejectIfThrottled writes a 429 and returns true when the caller is locked out.
+func rejectIfThrottled(w http.ResponseWriter, r *http.Request, email string, userID *uint) bool {
+	wait, err := loginRetryAfter(email, utils.ClientIP(r))
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return true
+	}
+	if wait <= 0 {
+		return false
+	}
+	recordLoginAttempt(r, email, userID, false, loginReasonLocked)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "Too many failed attempts, try again later", http.StatusTooManyRequests)
+	return true
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/bus"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+var (
+	errSwapChanged     = errors.New("slots changed hands since the swap")
+	errSwapNotAccepted = errors.New("swap is no longer accepted")
+)
+
+func isValidTeamRole(role models.TeamRole) bool {
+	switch role {
+	case models.RoleMember, models.RoleManager, models.RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// MyTeamRole handles GET /api/teams/{id}/role
+func MyTeamRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := access.RoleFor(uid, teamID)
+	if errors.Is(err, access.ErrNotMember) {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"role":        role,
+		"permissions": access.Permissions(role),
+	})
+}
+
+// TeamMember handles PATCH and DELETE /api/teams/{id}/members/{userId}
+// PATCH changes the member's role, DELETE removes them from the team.
+func TeamMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+	memberID, err := pathID(r, "userId")
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if !requireTeamPermission(w, uid, teamID, access.ManageMembers) {
+		return
+	}
+	// admins can't demote or remove themselves, so a team always keeps one
+	if memberID == uid {
+		http.Error(w, "You cannot change your own membership", http.StatusBadRequest)
+		return
+	}
+
+	var membership models.TeamMembership
+	if err := database.DB.Where("team_id = ? AND user_id = ?", teamID, memberID).First(&membership).Error; err != nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		var changed []models.Event
+		var cancelled []models.SwapRequest
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			changed, cancelled, err = removeMember(tx, teamID, memberID, uid)
+			return err
+		})
+		if errors.Is(err, errLastAdmin) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+			return
+		}
+		logAudit(r, "team.member_removed", "team", teamID, models.AuditDetails{"userId": memberID})
+		emitCancelledSwaps(r, cancelled)
+		publishSlots(changed...)
+		dropTeamSubscriptions(memberID, teamID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Member removed"})
+		return
+	}
+
+	var input struct {
+		Role models.TeamRole `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !isValidTeamRole(input.Role) {
+		http.Error(w, "Invalid role value", http.StatusBadRequest)
+		return
+	}
+
+	membership.Role = input.Role
+	if err := database.DB.Model(&membership).Update("role", input.Role).Error; err != nil {
+		http.Error(w, "Failed to update role", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "team.member_role_changed", "team", teamID, models.AuditDetails{"userId": memberID, "role": input.Role})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(membership)
+}
+
+// ReassignEvent handles POST /api/events/{id}/reassign
+// A team manager moves a slot to another member. Pending swaps on the slot
+// are cancelled first.
+func ReassignEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	eventID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		UserID uint `json:"userId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var event models.Event
+	if err := database.DB.First(&event, eventID).Error; err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+	if event.TeamID == nil {
+		http.Error(w, "Only team events can be reassigned", http.StatusBadRequest)
+		return
+	}
+	if !requireTeamPermission(w, uid, *event.TeamID, access.ReassignSlots) {
+		return
+	}
+	member, err := isTeamMember(database.DB, input.UserID, *event.TeamID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if !member {
+		http.Error(w, "userId is not a member of this team", http.StatusBadRequest)
+		return
+	}
+	missing, err := missingQualifications(database.DB, input.UserID, event)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if len(missing) > 0 {
+		http.Error(w, "User lacks qualifications: "+strings.Join(missing, ", "), http.StatusBadRequest)
+		return
+	}
+	if err := availabilityConflict(uid, input.UserID, event); err != nil {
+		writeAvailabilityError(w, err)
+		return
+	}
+
+	// assigning an open shift directly settles any claims on it
+	previousOwner := event.OwnerID()
+	var declined []uint
+	var released []models.Event
+	var cancelled []models.SwapRequest
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if released, cancelled, err = cancelPendingSwaps(tx, uid, "my_slot_id = ? OR their_slot_id = ?", event.ID, event.ID); err != nil {
+			return err
+		}
+		if isOpenShift(event) {
+			if err := assignOpenShift(tx, &event, input.UserID, uid, revReassigned); err != nil {
+				return err
+			}
+			declined, err = declinePendingClaims(tx, event.ID)
+			return err
+		}
+		before := event
+		event.UserID = &input.UserID
+		event.Status = models.SlotBusy
+		return saveEvent(tx, before, &event, revReassigned, &uid, nil)
+	})
+	if errors.Is(err, errShiftTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reassign event", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "event.reassigned", "event", event.ID, models.AuditDetails{"userId": input.UserID})
+	emitEventChanged(r, event, "reassigned", previousOwner)
+	emitCancelledSwaps(r, cancelled)
+	publishSlots(append(released, event)...)
+	if len(declined) > 0 {
+		notifyShiftFilled(event, input.UserID, declined)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+// RevertSwap handles POST /api/swaps/{id}/revert
+// A team manager undoes an accepted swap, provided neither slot has changed
+// hands again since.
+func RevertSwap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	swapID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid swap ID", http.StatusBadRequest)
+		return
+	}
+
+	var swap models.SwapRequest
+	if err := database.DB.First(&swap, swapID).Error; err != nil {
+		http.Error(w, "Swap request not found", http.StatusNotFound)
+		return
+	}
+	if swap.Status != models.SwapAccepted {
+		http.Error(w, "Only accepted swaps can be reverted", http.StatusBadRequest)
+		return
+	}
+
+	var mySlot, theirSlot models.Event
+	if err := database.DB.First(&mySlot, swap.MySlotID).Error; err != nil {
+		http.Error(w, "Slot not found", http.StatusNotFound)
+		return
+	}
+	if err := database.DB.First(&theirSlot, swap.TheirSlotID).Error; err != nil {
+		http.Error(w, "Slot not found", http.StatusNotFound)
+		return
+	}
+	if mySlot.TeamID == nil || !sameTeam(mySlot.TeamID, theirSlot.TeamID) {
+		http.Error(w, "Only team swaps can be reverted", http.StatusBadRequest)
+		return
+	}
+	if !requireTeamPermission(w, uid, *mySlot.TeamID, access.RevertSwaps) {
+		return
+	}
+
+	var released []models.Event
+	var cancelled []models.SwapRequest
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// re-read under row locks so concurrent reverts and new swaps on
+		// the same slots can't both pass the checks
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&swap, swapID).Error; err != nil {
+			return err
+		}
+		if swap.Status != models.SwapAccepted {
+			return errSwapNotAccepted
+		}
+		var slots []models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []uint{swap.MySlotID, swap.TheirSlotID}).Order("id").Find(&slots).Error; err != nil {
+			return err
+		}
+		if len(slots) != 2 {
+			return errSwapChanged
+		}
+		for _, s := range slots {
+			if s.ID == swap.MySlotID {
+				mySlot = s
+			} else {
+				theirSlot = s
+			}
+		}
+		// after an accepted swap the requester's slot belongs to the receiver
+		if !mySlot.OwnedBy(swap.ReceiverID) || !theirSlot.OwnedBy(swap.RequesterID) {
+			return errSwapChanged
+		}
+		var err error
+		if released, cancelled, err = cancelPendingSwaps(tx, uid, "my_slot_id IN ? OR their_slot_id IN ?",
+			[]uint{mySlot.ID, theirSlot.ID}, []uint{mySlot.ID, theirSlot.ID}); err != nil {
+			return err
+		}
+		myBefore, theirBefore := mySlot, theirSlot
+		mySlot.UserID, theirSlot.UserID = &swap.RequesterID, &swap.ReceiverID
+		mySlot.Status, theirSlot.Status = models.SlotBusy, models.SlotBusy
+		if err := saveEvent(tx, myBefore, &mySlot, revReverted, &uid, &swap.ID); err != nil {
+			return err
+		}
+		if err := saveEvent(tx, theirBefore, &theirSlot, revReverted, &uid, &swap.ID); err != nil {
+			return err
+		}
+		swap.Status = models.SwapReverted
+		return tx.Save(&swap).Error
+	})
+	if err == errSwapChanged || err == errSwapNotAccepted {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to revert swap", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "swap.reverted", "swap", swap.ID, nil)
+	emitSwap(r, bus.SwapReverted, swap)
+	emitCancelledSwaps(r, cancelled)
+	publishSlots(append(released, mySlot, theirSlot)...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(swap)
+}
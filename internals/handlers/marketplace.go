@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/config"
+	"github.com/jfernsio/slotswapper/internals/market"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/ws"
+)
+
+const (
+	socketPingInterval = 25 * time.Second
+	// socketReadTimeout allows one missed pong before the client is dropped.
+	socketReadTimeout = 2*socketPingInterval + 10*time.Second
+	socketMaxMessage  = 4 << 10
+)
+
+// publishSlots pushes the slots' current state to their team's marketplace
+// channel. Call it once the change has committed; team-less slots are
+// skipped.
+func publishSlots(events ...models.Event) {
+	for _, e := range events {
+		if e.TeamID != nil {
+			market.Default.Publish(market.Delta(e))
+		}
+	}
+}
+
+// dropTeamSubscriptions cuts the user's marketplace sockets off from a team
+// they no longer belong to. Call it once the membership change has
+// committed.
+func dropTeamSubscriptions(userID, teamID uint) {
+	for _, c := range market.Default.LeaveTeam(userID, teamID) {
+		market.Default.SendTo(c, socketReply{Type: "unsubscribed", TeamID: teamID})
+	}
+}
+
+type socketCommand struct {
+	Action string `json:"action"`
+	TeamID uint   `json:"teamId"`
+}
+
+type socketReply struct {
+	Type    string `json:"type"`
+	TeamID  uint   `json:"teamId,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// MarketplaceSocket handles GET /api/marketplace/ws
+// It upgrades to a WebSocket. The client sends
+// {"action":"subscribe","teamId":N} (or "unsubscribe") for teams it belongs
+// to and then receives a "slot" message whenever a slot on that team's board
+// changes. Clients that fall behind are disconnected with code 1013 and
+// should reload the board when they reconnect.
+func MarketplaceSocket(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	client, err := market.Default.Register(uid, config.GetMarketplaceConnectionLimit())
+	if errors.Is(err, market.ErrTooManyConnections) {
+		http.Error(w, "Too many marketplace connections", http.StatusTooManyRequests)
+		return
+	}
+	defer market.Default.Unregister(client)
+
+	conn, err := ws.Upgrade(w, r, config.GetWebSocketOrigins()...)
+	if err != nil {
+		return
+	}
+	defer conn.Close(ws.CloseNormal, "")
+	conn.MaxMessageSize = socketMaxMessage
+	conn.SetReadDeadline(time.Now().Add(socketReadTimeout))
+	conn.PongHandler = func() {
+		conn.SetReadDeadline(time.Now().Add(socketReadTimeout))
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ping := time.NewTicker(socketPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-client.Dropped:
+				if client.Err == market.ErrRevoked {
+					conn.Close(ws.ClosePolicyViolation, client.Err.Error())
+				} else {
+					conn.Close(ws.CloseTryAgainLater, client.Err.Error())
+				}
+				return
+			case msg := <-client.Send:
+				if conn.WriteMessage(ws.OpText, msg) != nil {
+					conn.Close(ws.CloseGoingAway, "")
+					return
+				}
+			case <-ping.C:
+				if conn.Ping() != nil {
+					conn.Close(ws.CloseGoingAway, "")
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		op, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(socketReadTimeout))
+		var cmd socketCommand
+		if op != ws.OpText || json.Unmarshal(data, &cmd) != nil {
+			market.Default.SendTo(client, socketReply{Type: "error", Message: "Invalid message"})
+			continue
+		}
+		switch cmd.Action {
+		case "subscribe":
+			allowed, err := access.Can(uid, cmd.TeamID, access.ViewTeam)
+			if err != nil {
+				market.Default.SendTo(client, socketReply{Type: "error", TeamID: cmd.TeamID, Message: "db error"})
+				continue
+			}
+			if !allowed {
+				market.Default.SendTo(client, socketReply{Type: "error", TeamID: cmd.TeamID, Message: "Team not found"})
+				continue
+			}
+			market.Default.Subscribe(client, cmd.TeamID)
+			// check again in case the membership ended while subscribing;
+			// dropTeamSubscriptions may already have run
+			if allowed, err := access.Can(uid, cmd.TeamID, access.ViewTeam); err != nil || !allowed {
+				market.Default.Unsubscribe(client, cmd.TeamID)
+				market.Default.SendTo(client, socketReply{Type: "error", TeamID: cmd.TeamID, Message: "Team not found"})
+				continue
+			}
+			market.Default.SendTo(client, socketReply{Type: "subscribed", TeamID: cmd.TeamID})
+		case "unsubscribe":
+			market.Default.Unsubscribe(client, cmd.TeamID)
+			market.Default.SendTo(client, socketReply{Type: "unsubscribed", TeamID: cmd.TeamID})
+		default:
+			market.Default.SendTo(client, socketReply{Type: "error", Message: "Unknown action"})
+		}
+	}
+}
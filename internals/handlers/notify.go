@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jfernsio/slotswapper/internals/bus"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/mailer"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/webhook"
+)
+
+func swapEmails(p models.NotificationPrefs) bool  { return p.SwapEmails }
+func shiftEmails(p models.NotificationPrefs) bool { return p.ShiftEmails }
+
+// mailUser queues the named email template for the user unless the
+// preference picked by wants is off. Failures are logged; they never fail
+// the request.
+func mailUser(userID uint, wants func(models.NotificationPrefs) bool, name string, data map[string]interface{}) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		log.Printf("failed to load user %d for %s email: %v", userID, name, err)
+		return
+	}
+	if user.AnonymizedAt != nil || !wants(user.Notifications) {
+		return
+	}
+	if err := mailer.Queue(database.DB, user, name, data); err != nil {
+		log.Printf("failed to queue %s email for user %d: %v", name, userID, err)
+	}
+}
+
+// mailSwap emails the people a swap change concerns, leaving out whoever
+// made it: the receiver hears about a new request, both sides about an
+// outcome.
+func mailSwap(r *http.Request, typ string, swap models.SwapRequest, mySlot, theirSlot models.Event) {
+	uid, _ := middleware.UserIDFromContext(r.Context())
+	data := map[string]interface{}{"MySlot": mySlot, "TheirSlot": theirSlot, "Reason": swap.Reason}
+
+	if typ == bus.SwapCreated {
+		var requester models.User
+		if err := database.DB.Select("name").First(&requester, swap.RequesterID).Error; err != nil {
+			log.Printf("failed to load requester of swap %d for email: %v", swap.ID, err)
+			return
+		}
+		data["Requester"] = requester.Name
+		mailUser(swap.ReceiverID, swapEmails, "swap_requested", data)
+		return
+	}
+	data["Outcome"] = strings.TrimPrefix(typ, "swap.")
+	for _, id := range []uint{swap.RequesterID, swap.ReceiverID} {
+		if id != uid {
+			mailUser(id, swapEmails, "swap_outcome", data)
+		}
+	}
+}
+
+// emit publishes a domain event to the given users, leaving out whoever
+// made the request since they already know. Failures are logged.
+func emit(r *http.Request, typ string, data models.JSONMap, userIDs ...uint) {
+	uid, _ := middleware.UserIDFromContext(r.Context())
+	recipients := make([]uint, 0, len(userIDs))
+	for _, id := range userIDs {
+		if id != uid {
+			recipients = append(recipients, id)
+		}
+	}
+	if err := bus.Default.Emit(database.DB, typ, data, recipients...); err != nil {
+		log.Printf("failed to emit %s: %v", typ, err)
+	}
+}
+
+// emitSwap tells both sides of a swap about its new state, in the app and
+// by email, and passes it on to the organization's webhooks.
+func emitSwap(r *http.Request, typ string, swap models.SwapRequest) {
+	emit(r, typ, models.JSONMap{
+		"swapId":      swap.ID,
+		"status":      swap.Status,
+		"mySlotId":    swap.MySlotID,
+		"theirSlotId": swap.TheirSlotID,
+	}, swap.RequesterID, swap.ReceiverID)
+
+	var mySlot, theirSlot models.Event
+	if err := database.DB.Unscoped().First(&mySlot, swap.MySlotID).Error; err != nil {
+		log.Printf("failed to load slots of swap %d: %v", swap.ID, err)
+		return
+	}
+	if err := database.DB.Unscoped().First(&theirSlot, swap.TheirSlotID).Error; err != nil {
+		log.Printf("failed to load slots of swap %d: %v", swap.ID, err)
+		return
+	}
+	mailSwap(r, typ, swap, mySlot, theirSlot)
+	dispatchWebhooks(r, mySlot.TeamID, typ, models.JSONMap{
+		"swap":      swap,
+		"mySlot":    mySlot,
+		"theirSlot": theirSlot,
+	})
+}
+
+// emitCancelledSwaps tells both sides of each swap that it was cancelled
+// because a slot or one of the parties went away.
+func emitCancelledSwaps(r *http.Request, swaps []models.SwapRequest) {
+	for _, swap := range swaps {
+		emitSwap(r, bus.SwapCancelled, swap)
+	}
+}
+
+// dispatchWebhooks queues a change for the webhooks of the team's
+// organization. The caller is recorded as the actor. Failures are logged.
+func dispatchWebhooks(r *http.Request, teamID *uint, typ string, data models.JSONMap) {
+	if uid, ok := middleware.UserIDFromContext(r.Context()); ok {
+		data["actorId"] = uid
+	}
+	if err := webhook.Dispatch(database.DB, teamID, typ, data); err != nil {
+		log.Printf("failed to dispatch %s webhooks: %v", typ, err)
+	}
+}
+
+// emitEventChanged tells the event's owner, and anyone else listed such as
+// a previous owner, that the event changed. Webhooks get the event with the
+// field changes from its latest revision, so they can see who it moved from.
+func emitEventChanged(r *http.Request, event models.Event, action string, others ...uint) {
+	emit(r, bus.EventChanged, models.JSONMap{
+		"eventId": event.ID,
+		"action":  action,
+		"status":  event.Status,
+	}, append([]uint{event.OwnerID()}, others...)...)
+
+	data := models.JSONMap{"action": action, "event": event}
+	var rev models.EventRevision
+	if err := database.DB.Where("event_id = ?", event.ID).Order("id DESC").First(&rev).Error; err == nil {
+		data["changes"] = rev.Changes
+	}
+	dispatchWebhooks(r, event.TeamID, "event."+action, data)
+}
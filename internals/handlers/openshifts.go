@@ -0,0 +1,558 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/rules"
+)
+
+var (
+	errShiftTaken   = errors.New("shift has already been taken")
+	errClaimDecided = errors.New("claim has already been decided")
+)
+
+func isValidClaimMode(mode models.ClaimMode) bool {
+	return mode == models.ClaimFirstCome || mode == models.ClaimManager
+}
+
+// isOpenShift reports whether the event sits unassigned in the pool.
+func isOpenShift(event models.Event) bool {
+	return event.UserID == nil && event.Status == models.SlotOpen
+}
+
+// claimLaborViolations returns the labor rules the user would break by
+// taking the shift on top of their current schedule.
+func claimLaborViolations(db *gorm.DB, userID uint, shift models.Event) ([]rules.Violation, models.LaborEnforcement, error) {
+	engine, enforcement, err := laborEngine(db, shift.TeamID)
+	if err != nil || engine == nil {
+		return nil, "", err
+	}
+	violations, err := newViolations(db, engine, userID, models.Event{}, shift)
+	return violations, enforcement, err
+}
+
+// checkClaimant makes sure the user may own the shift, wording errors for
+// viewer. It writes the error response and returns false if not.
+// Warn-level violations are returned for the caller to pass on.
+func checkClaimant(w http.ResponseWriter, viewer, userID uint, shift models.Event) ([]rules.Violation, bool) {
+	missing, err := missingQualifications(database.DB, userID, shift)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(missing) > 0 {
+		http.Error(w, "Missing qualifications: "+strings.Join(missing, ", "), http.StatusForbidden)
+		return nil, false
+	}
+	if err := availabilityConflict(viewer, userID, shift); err != nil {
+		writeAvailabilityError(w, err)
+		return nil, false
+	}
+	violations, enforcement, err := claimLaborViolations(database.DB, userID, shift)
+	if err != nil {
+		http.Error(w, "Failed to check labor rules", http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(violations) > 0 && enforcement == models.EnforceBlock {
+		writeViolations(w, violations)
+		return nil, false
+	}
+	return violations, true
+}
+
+// assignOpenShift hands the shift to the user if it is still open. The
+// conditional update makes concurrent claims safe: only one of them can
+// match the unassigned row.
+func assignOpenShift(tx *gorm.DB, shift *models.Event, userID, actorID uint, action string) error {
+	before := *shift
+	res := tx.Model(&models.Event{}).
+		Where("id = ? AND user_id IS NULL AND status = ?", shift.ID, models.SlotOpen).
+		Updates(map[string]interface{}{"user_id": userID, "status": models.SlotBusy})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errShiftTaken
+	}
+	shift.UserID = &userID
+	shift.Status = models.SlotBusy
+	return recordRevision(tx, before, *shift, action, &actorID, nil)
+}
+
+// openShift puts a team event its owner can no longer work back into the
+// pool, to be claimed first come unless it already has a claim mode.
+func openShift(tx *gorm.DB, event *models.Event, actorID *uint) error {
+	before := *event
+	event.UserID = nil
+	event.Status = models.SlotOpen
+	if event.ClaimMode == "" {
+		event.ClaimMode = models.ClaimFirstCome
+	}
+	return saveEvent(tx, before, event, revOpened, actorID, nil)
+}
+
+// canTakeShift reports whether the event may be handed to the user without
+// asking: they are on its team, hold its qualifications, are available and
+// would break no blocking labor rule.
+func canTakeShift(db *gorm.DB, userID uint, event models.Event) (bool, error) {
+	if event.TeamID == nil {
+		return false, nil
+	}
+	if member, err := isTeamMember(db, userID, *event.TeamID); err != nil || !member {
+		return false, err
+	}
+	if missing, err := missingQualifications(db, userID, event); err != nil || len(missing) > 0 {
+		return false, err
+	}
+	if block, err := unavailableDuring(db, userID, event.StartTime, event.EndTime); err != nil || block != nil {
+		return false, err
+	}
+	violations, enforcement, err := claimLaborViolations(db, userID, event)
+	if err != nil {
+		return false, err
+	}
+	return len(violations) == 0 || enforcement != models.EnforceBlock, nil
+}
+
+// declinePendingClaims declines every pending claim on the shift and
+// returns the users whose claims were declined.
+func declinePendingClaims(tx *gorm.DB, eventID uint) ([]uint, error) {
+	var claims []models.ShiftClaim
+	if err := tx.Where("event_id = ? AND status = ?", eventID, models.ClaimPending).Find(&claims).Error; err != nil {
+		return nil, err
+	}
+	if len(claims) == 0 {
+		return nil, nil
+	}
+	if err := tx.Model(&models.ShiftClaim{}).
+		Where("event_id = ? AND status = ?", eventID, models.ClaimPending).
+		Updates(map[string]interface{}{"status": models.ClaimDeclined, "decided_at": time.Now()}).Error; err != nil {
+		return nil, err
+	}
+	users := make([]uint, 0, len(claims))
+	for _, c := range claims {
+		users = append(users, c.UserID)
+	}
+	return users, nil
+}
+
+// OpenShiftView is an open shift as seen by a team member.
+type OpenShiftView struct {
+	models.Event
+	Qualified bool                `json:"qualified"`
+	MyClaim   *models.ClaimStatus `json:"myClaim"`
+}
+
+// OpenShifts handles GET and POST /api/teams/{id}/open-shifts
+// GET lists upcoming unassigned shifts, POST lets a manager add one.
+func OpenShifts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		createOpenShift(w, r, uid, teamID)
+		return
+	}
+	if !requireTeamPermission(w, uid, teamID, access.ViewTeam) {
+		return
+	}
+
+	var shifts []models.Event
+	if err := database.DB.
+		Where("team_id = ? AND user_id IS NULL AND status = ? AND end_time > ?", teamID, models.SlotOpen, time.Now()).
+		Order("start_time").
+		Find(&shifts).Error; err != nil {
+		http.Error(w, "Error fetching open shifts", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]uint, 0, len(shifts))
+	for _, s := range shifts {
+		ids = append(ids, s.ID)
+	}
+	var claims []models.ShiftClaim
+	if len(ids) > 0 {
+		if err := database.DB.Where("user_id = ? AND event_id IN ?", uid, ids).Find(&claims).Error; err != nil {
+			http.Error(w, "Error fetching open shifts", http.StatusInternalServerError)
+			return
+		}
+	}
+	myClaims := map[uint]models.ClaimStatus{}
+	for _, c := range claims {
+		myClaims[c.EventID] = c.Status
+	}
+
+	out := make([]OpenShiftView, 0, len(shifts))
+	for _, s := range shifts {
+		missing, err := missingQualifications(database.DB, uid, s)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		view := OpenShiftView{Event: s, Qualified: len(missing) == 0}
+		if status, ok := myClaims[s.ID]; ok {
+			view.MyClaim = &status
+		}
+		out = append(out, view)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func createOpenShift(w http.ResponseWriter, r *http.Request, uid, teamID uint) {
+	if !requireTeamPermission(w, uid, teamID, access.ManageEvents) {
+		return
+	}
+
+	var input struct {
+		Title                  string           `json:"title"`
+		StartTime              string           `json:"startTime"`
+		EndTime                string           `json:"endTime"`
+		ClaimMode              models.ClaimMode `json:"claimMode"`
+		RequiredQualifications []string         `json:"requiredQualifications"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	input.Title = strings.TrimSpace(input.Title)
+	if input.Title == "" {
+		http.Error(w, "title is required", http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse(time.RFC3339, input.StartTime)
+	if err != nil {
+		http.Error(w, "Invalid startTime format", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse(time.RFC3339, input.EndTime)
+	if err != nil {
+		http.Error(w, "Invalid endTime format", http.StatusBadRequest)
+		return
+	}
+	if !end.After(start) {
+		http.Error(w, "endTime must be after startTime", http.StatusBadRequest)
+		return
+	}
+	if input.ClaimMode == "" {
+		input.ClaimMode = models.ClaimFirstCome
+	}
+	if !isValidClaimMode(input.ClaimMode) {
+		http.Error(w, "claimMode must be first_come or manager", http.StatusBadRequest)
+		return
+	}
+	required, err := normalizeQualifications(input.RequiredQualifications)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shift := models.Event{
+		Title:                  input.Title,
+		StartTime:              start,
+		EndTime:                end,
+		Status:                 models.SlotOpen,
+		TeamID:                 &teamID,
+		ClaimMode:              input.ClaimMode,
+		RequiredQualifications: required,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&shift).Error; err != nil {
+			return err
+		}
+		return recordCreation(tx, shift, revCreated, &uid)
+	})
+	if err != nil {
+		http.Error(w, "Failed to create open shift", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "shift.created", "event", shift.ID, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(shift)
+}
+
+// ClaimShift handles POST and DELETE /api/events/{id}/claim
+// In first-come mode POST assigns the shift straight away; in manager mode
+// it files a claim for a manager to pick from. DELETE withdraws a pending
+// claim.
+func ClaimShift(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	eventID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		res := database.DB.Model(&models.ShiftClaim{}).
+			Where("event_id = ? AND user_id = ? AND status = ?", eventID, uid, models.ClaimPending).
+			Update("status", models.ClaimWithdrawn)
+		if res.Error != nil {
+			http.Error(w, "Failed to withdraw claim", http.StatusInternalServerError)
+			return
+		}
+		if res.RowsAffected == 0 {
+			http.Error(w, "No pending claim on this shift", http.StatusNotFound)
+			return
+		}
+		logAudit(r, "shift.claim_withdrawn", "event", eventID, nil)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Claim withdrawn"})
+		return
+	}
+
+	var shift models.Event
+	if err := database.DB.First(&shift, eventID).Error; err != nil || shift.TeamID == nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+	if !requireTeamPermission(w, uid, *shift.TeamID, access.ClaimShifts) {
+		return
+	}
+	if !isOpenShift(shift) {
+		http.Error(w, errShiftTaken.Error(), http.StatusConflict)
+		return
+	}
+	warnings, ok := checkClaimant(w, uid, uid, shift)
+	if !ok {
+		return
+	}
+
+	if shift.ClaimMode == models.ClaimManager || len(warnings) > 0 {
+		// warnings need a manager to override them, like swaps do
+		claim, err := fileShiftClaim(eventID, uid)
+		if errors.Is(err, errClaimDecided) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to claim shift", http.StatusInternalServerError)
+			return
+		}
+		logAudit(r, "shift.claim_filed", "event", eventID, models.AuditDetails{"claimId": claim.ID})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"claim":    claim,
+			"warnings": warnings,
+		})
+		return
+	}
+
+	var declined []uint
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := assignOpenShift(tx, &shift, uid, uid, revClaimed); err != nil {
+			return err
+		}
+		now := time.Now()
+		claim := models.ShiftClaim{EventID: shift.ID, UserID: uid, Status: models.ClaimGranted, DecidedAt: &now}
+		if err := tx.Where("event_id = ? AND user_id = ?", shift.ID, uid).
+			Assign(map[string]interface{}{"status": models.ClaimGranted, "decided_at": now}).
+			FirstOrCreate(&claim).Error; err != nil {
+			return err
+		}
+		declined, err = declinePendingClaims(tx, shift.ID)
+		return err
+	})
+	if errors.Is(err, errShiftTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to claim shift", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "shift.claimed", "event", shift.ID, nil)
+	emitEventChanged(r, shift, "claimed")
+	notifyShiftFilled(shift, uid, declined)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shift)
+}
+
+// fileShiftClaim records a pending claim, reopening a withdrawn one.
+func fileShiftClaim(eventID, userID uint) (models.ShiftClaim, error) {
+	var claim models.ShiftClaim
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("event_id = ? AND user_id = ?", eventID, userID).First(&claim).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			claim = models.ShiftClaim{EventID: eventID, UserID: userID, Status: models.ClaimPending}
+			return tx.Create(&claim).Error
+		}
+		if err != nil {
+			return err
+		}
+		switch claim.Status {
+		case models.ClaimPending:
+			return nil
+		case models.ClaimWithdrawn:
+			claim.Status = models.ClaimPending
+			return tx.Save(&claim).Error
+		default:
+			return errClaimDecided
+		}
+	})
+	return claim, err
+}
+
+// ShiftClaims handles GET /api/events/{id}/claims
+func ShiftClaims(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	eventID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	var shift models.Event
+	if err := database.DB.First(&shift, eventID).Error; err != nil || shift.TeamID == nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+	if !requireTeamPermission(w, uid, *shift.TeamID, access.ReassignSlots) {
+		return
+	}
+
+	var claims []models.ShiftClaim
+	if err := database.DB.Where("event_id = ?", eventID).Order("created_at").Find(&claims).Error; err != nil {
+		http.Error(w, "Error fetching claims", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claims)
+}
+
+// GrantShiftClaim handles POST /api/events/{id}/claims/{claimId}/grant
+// A manager picks one of the pending claims; the others are declined.
+func GrantShiftClaim(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	eventID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+	claimID, err := pathID(r, "claimId")
+	if err != nil {
+		http.Error(w, "Invalid claim ID", http.StatusBadRequest)
+		return
+	}
+
+	var shift models.Event
+	if err := database.DB.First(&shift, eventID).Error; err != nil || shift.TeamID == nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+	if !requireTeamPermission(w, uid, *shift.TeamID, access.ReassignSlots) {
+		return
+	}
+	var claim models.ShiftClaim
+	if err := database.DB.Where("id = ? AND event_id = ?", claimID, eventID).First(&claim).Error; err != nil {
+		http.Error(w, "Claim not found", http.StatusNotFound)
+		return
+	}
+	if claim.Status != models.ClaimPending {
+		http.Error(w, errClaimDecided.Error(), http.StatusConflict)
+		return
+	}
+	if !isOpenShift(shift) {
+		http.Error(w, errShiftTaken.Error(), http.StatusConflict)
+		return
+	}
+	// granting overrides warnings, but blocking violations still stand
+	if _, ok := checkClaimant(w, uid, claim.UserID, shift); !ok {
+		return
+	}
+
+	var declined []uint
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := assignOpenShift(tx, &shift, claim.UserID, uid, revClaimed); err != nil {
+			return err
+		}
+		now := time.Now()
+		res := tx.Model(&claim).Where("status = ?", models.ClaimPending).
+			Updates(map[string]interface{}{"status": models.ClaimGranted, "decided_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errClaimDecided
+		}
+		claim.Status, claim.DecidedAt = models.ClaimGranted, &now
+		declined, err = declinePendingClaims(tx, shift.ID)
+		return err
+	})
+	if errors.Is(err, errShiftTaken) || errors.Is(err, errClaimDecided) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to grant claim", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "shift.claim_granted", "event", shift.ID, models.AuditDetails{"claimId": claim.ID, "userId": claim.UserID})
+	emitEventChanged(r, shift, "claimed")
+	notifyShiftFilled(shift, claim.UserID, declined)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claim)
+}
+
+// notifyShiftFilled tells the new owner, and everyone whose claim lost out,
+// how the shift was handed out.
+func notifyShiftFilled(shift models.Event, winner uint, declined []uint) {
+	data := map[string]interface{}{"Shift": shift}
+	mailUser(winner, shiftEmails, "shift_won", data)
+	for _, id := range declined {
+		mailUser(id, shiftEmails, "shift_lost", data)
+	}
+}
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+// loadSwapPolicy returns the team's policy, or an empty one if none is set.
+func loadSwapPolicy(teamID uint) (models.TeamSwapPolicy, error) {
+	policy := models.TeamSwapPolicy{TeamID: teamID}
+	err := database.DB.Where("team_id = ?", teamID).First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return policy, nil
+	}
+	return policy, err
+}
+
+// slotPolicyReasons explains why the team policy stops slot from being
+// traded at now. An empty result means the policy allows it.
+func slotPolicyReasons(slot models.Event, now time.Time) ([]string, error) {
+	if slot.TeamID == nil {
+		return nil, nil
+	}
+	policy, err := loadSwapPolicy(*slot.TeamID)
+	if err != nil {
+		return nil, err
+	}
+
+	var reasons []string
+	if policy.MinLeadHours > 0 {
+		cutoff := now.Add(time.Duration(policy.MinLeadHours * float64(time.Hour)))
+		if slot.StartTime.Before(cutoff) {
+			reasons = append(reasons, fmt.Sprintf("slot starts within the %.0f hour minimum lead time", policy.MinLeadHours))
+		}
+	}
+
+	var blackouts []models.SwapBlackout
+	if err := database.DB.
+		Where("team_id = ? AND start_time < ? AND end_time > ?", *slot.TeamID, slot.EndTime, slot.StartTime).
+		Find(&blackouts).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range blackouts {
+		reason := fmt.Sprintf("slot falls in a blackout period (%s – %s)",
+			b.StartTime.UTC().Format(time.RFC3339), b.EndTime.UTC().Format(time.RFC3339))
+		if b.Reason != "" {
+			reason += ": " + b.Reason
+		}
+		reasons = append(reasons, reason)
+	}
+	return reasons, nil
+}
+
+// checkSwapPolicy applies the team policy to both slots of a swap.
+func checkSwapPolicy(mySlot, theirSlot models.Event) error {
+	now := time.Now()
+	for _, slot := range []models.Event{mySlot, theirSlot} {
+		reasons, err := slotPolicyReasons(slot, now)
+		if err != nil {
+			return err
+		}
+		if len(reasons) > 0 {
+			return &policyError{fmt.Sprintf("%q can't be swapped: %s", slot.Title, strings.Join(reasons, "; "))}
+		}
+	}
+	return nil
+}
+
+// checkOpenRequestLimit stops a user from holding more open swap requests
+// in the team than the policy allows.
+func checkOpenRequestLimit(userID uint, teamID *uint) error {
+	if teamID == nil {
+		return nil
+	}
+	policy, err := loadSwapPolicy(*teamID)
+	if err != nil || policy.MaxOpenRequests <= 0 {
+		return err
+	}
+	var open int64
+	if err := database.DB.Model(&models.SwapRequest{}).
+		Where("requester_id = ? AND status IN ? AND my_slot_id IN (?)", userID, openSwapStatuses,
+			database.DB.Model(&models.Event{}).Select("id").Where("team_id = ?", *teamID)).
+		Count(&open).Error; err != nil {
+		return err
+	}
+	if open >= int64(policy.MaxOpenRequests) {
+		return &policyError{fmt.Sprintf("you already have %d open swap requests, the team limit is %d", open, policy.MaxOpenRequests)}
+	}
+	return nil
+}
+
+// policyError is a policy refusal the client should see verbatim.
+type policyError struct{ msg string }
+
+func (e *policyError) Error() string { return e.msg }
+
+// writePolicyError maps a policy check result to a response.
+func writePolicyError(w http.ResponseWriter, err error) {
+	var perr *policyError
+	if errors.As(err, &perr) {
+		http.Error(w, perr.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	http.Error(w, "Failed to check swap policy", http.StatusInternalServerError)
+}
+
+// SwapPolicy handles GET and PUT /api/teams/{id}/swap-policy
+// GET also returns the blackouts that haven't ended yet.
+func SwapPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	perm := access.ViewTeam
+	if r.Method == http.MethodPut {
+		perm = access.ManageTeam
+	}
+	if !requireTeamPermission(w, uid, teamID, perm) {
+		return
+	}
+
+	policy, err := loadSwapPolicy(teamID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var input struct {
+			MinLeadHours    float64 `json:"minLeadHours"`
+			MaxOpenRequests int     `json:"maxOpenRequests"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if input.MinLeadHours < 0 || input.MaxOpenRequests < 0 {
+			http.Error(w, "limits must not be negative", http.StatusBadRequest)
+			return
+		}
+		policy.MinLeadHours = input.MinLeadHours
+		policy.MaxOpenRequests = input.MaxOpenRequests
+		if err := database.DB.Save(&policy).Error; err != nil {
+			http.Error(w, "Failed to save swap policy", http.StatusInternalServerError)
+			return
+		}
+		logAudit(r, "team.swap_policy_updated", "team", teamID, models.AuditDetails{
+			"minLeadHours":    policy.MinLeadHours,
+			"maxOpenRequests": policy.MaxOpenRequests,
+		})
+	}
+
+	var blackouts []models.SwapBlackout
+	if err := database.DB.Where("team_id = ? AND end_time > ?", teamID, time.Now()).
+		Order("start_time").Find(&blackouts).Error; err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policy":    policy,
+		"blackouts": blackouts,
+	})
+}
+
+// CreateBlackout handles POST /api/teams/{id}/blackouts
+func CreateBlackout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+	if !requireTeamPermission(w, uid, teamID, access.ManageTeam) {
+		return
+	}
+
+	var input struct {
+		StartTime string `json:"startTime"`
+		EndTime   string `json:"endTime"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse(time.RFC3339, input.StartTime)
+	if err != nil {
+		http.Error(w, "Invalid startTime format", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse(time.RFC3339, input.EndTime)
+	if err != nil {
+		http.Error(w, "Invalid endTime format", http.StatusBadRequest)
+		return
+	}
+	if !end.After(start) {
+		http.Error(w, "endTime must be after startTime", http.StatusBadRequest)
+		return
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if len(input.Reason) > 200 {
+		http.Error(w, "reason must be at most 200 characters", http.StatusBadRequest)
+		return
+	}
+
+	blackout := models.SwapBlackout{
+		TeamID:      teamID,
+		StartTime:   start,
+		EndTime:     end,
+		Reason:      input.Reason,
+		CreatedByID: uid,
+	}
+	if err := database.DB.Create(&blackout).Error; err != nil {
+		http.Error(w, "Failed to create blackout", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "team.blackout_created", "team", teamID, models.AuditDetails{"blackoutId": blackout.ID})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(blackout)
+}
+
+// DeleteBlackout handles DELETE /api/teams/{id}/blackouts/{blackoutId}
+func DeleteBlackout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+	blackoutID, err := pathID(r, "blackoutId")
+	if err != nil {
+		http.Error(w, "Invalid blackout ID", http.StatusBadRequest)
+		return
+	}
+	if !requireTeamPermission(w, uid, teamID, access.ManageTeam) {
+		return
+	}
+
+	res := database.DB.Where("id = ? AND team_id = ?", blackoutID, teamID).Delete(&models.SwapBlackout{})
+	if res.Error != nil {
+		http.Error(w, "Failed to delete blackout", http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		http.Error(w, "Blackout not found", http.StatusNotFound)
+		return
+	}
+	logAudit(r, "team.blackout_deleted", "team", teamID, models.AuditDetails{"blackoutId": blackoutID})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Blackout deleted"})
+}
+
+// EventTradability handles GET /api/events/{id}/tradability
+// It tells clients whether a slot can be put up for, or taken in, a swap
+// right now, and why not.
+func EventTradability(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	eventID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	var event models.Event
+	if err := database.DB.First(&event, eventID).Error; err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+	if !event.OwnedBy(uid) {
+		if event.TeamID == nil {
+			http.Error(w, "Event not found", http.StatusNotFound)
+			return
+		}
+		if !requireTeamPermission(w, uid, *event.TeamID, access.ViewTeam) {
+			return
+		}
+	}
+
+	reasons, err := slotPolicyReasons(event, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to check swap policy", http.StatusInternalServerError)
+		return
+	}
+	if event.Status == models.SlotSwapPending {
+		reasons = append(reasons, "slot is locked in a pending swap")
+	}
+	if event.OwnedBy(uid) {
+		if err := checkOpenRequestLimit(uid, event.TeamID); err != nil {
+			var perr *policyError
+			if !errors.As(err, &perr) {
+				http.Error(w, "Failed to check swap policy", http.StatusInternalServerError)
+				return
+			}
+			reasons = append(reasons, perr.Error())
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"eventId":  event.ID,
+		"tradable": len(reasons) == 0,
+		"reasons":  reasons,
+	})
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jfernsio/slotswapper/internals/auth"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/mailer"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/storage"
+)
+
+const maxAvatarBytes = 2 << 20
+
+// avatarExtensions lists the image types accepted for avatars.
+var avatarExtensions = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+type ProfileResponse struct {
+	ID            uint                     `json:"id"`
+	Name          string                   `json:"name"`
+	Email         string                   `json:"email"`
+	EmailVerified bool                     `json:"emailVerified"`
+	PendingEmail  string                   `json:"pendingEmail,omitempty"`
+	TimeZone      string                   `json:"timeZone"`
+	Locale        string                   `json:"locale"`
+	TwoFactor     bool                     `json:"twoFactorEnabled"`
+	HasAvatar     bool                     `json:"hasAvatar"`
+	Notifications models.NotificationPrefs `json:"notifications"`
+	CreatedAt     time.Time                `json:"createdAt"`
+}
+
+func profileResponse(user models.User) ProfileResponse {
+	return ProfileResponse{
+		ID:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		PendingEmail:  user.PendingEmail,
+		TimeZone:      user.TimeZone,
+		Locale:        user.Locale,
+		TwoFactor:     user.TOTPEnabled,
+		HasAvatar:     user.AvatarKey != "",
+		Notifications: user.Notifications,
+		CreatedAt:     user.CreatedAt,
+	}
+}
+
+// Profile handles GET and PATCH /api/profile
+func Profile(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getProfile(w, r)
+	case http.MethodPatch:
+		updateProfile(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func getProfile(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, uid).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profileResponse(user))
+}
+
+func updateProfile(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// pointer fields so omitted keys leave the value unchanged
+	type UpdateInput struct {
+		Name          *string `json:"name"`
+		Email         *string `json:"email"`
+		TimeZone      *string `json:"timeZone"`
+		Locale        *string `json:"locale"`
+		Notifications *struct {
+			SwapEmails  *bool `json:"swapEmails"`
+			ShiftEmails *bool `json:"shiftEmails"`
+			DailyDigest *bool `json:"dailyDigest"`
+			DigestHour  *int  `json:"digestHour"`
+		} `json:"notifications"`
+	}
+
+	var input UpdateInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, uid).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		updates["name"] = name
+	}
+	if input.TimeZone != nil {
+		tz := strings.TrimSpace(*input.TimeZone)
+		if _, err := time.LoadLocation(tz); err != nil || tz == "" {
+			http.Error(w, "Invalid time zone", http.StatusBadRequest)
+			return
+		}
+		updates["time_zone"] = tz
+	}
+	if input.Locale != nil {
+		locale := strings.ToLower(strings.TrimSpace(*input.Locale))
+		if !mailer.HasLocale(locale) {
+			http.Error(w, "Unsupported locale, use one of: "+strings.Join(mailer.Locales(), ", "), http.StatusBadRequest)
+			return
+		}
+		updates["locale"] = locale
+	}
+	if n := input.Notifications; n != nil {
+		if n.SwapEmails != nil {
+			updates["notify_swap_emails"] = *n.SwapEmails
+		}
+		if n.ShiftEmails != nil {
+			updates["notify_shift_emails"] = *n.ShiftEmails
+		}
+		if n.DailyDigest != nil {
+			updates["notify_daily_digest"] = *n.DailyDigest
+		}
+		if n.DigestHour != nil {
+			if *n.DigestHour < 0 || *n.DigestHour > 23 {
+				http.Error(w, "digestHour must be between 0 and 23", http.StatusBadRequest)
+				return
+			}
+			updates["notify_digest_hour"] = *n.DigestHour
+		}
+	}
+
+	// a new address only takes effect once it has been verified
+	var newEmail string
+	if input.Email != nil {
+		email := auth.NormalizeEmail(*input.Email)
+		if err := auth.ValidateEmail(email); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if email != user.Email {
+			var taken int64
+			if err := database.DB.Model(&models.User{}).Where("LOWER(email) = ? AND id <> ?", email, user.ID).Count(&taken).Error; err != nil {
+				http.Error(w, "db error", http.StatusInternalServerError)
+				return
+			}
+			if taken > 0 {
+				http.Error(w, "email already registered", http.StatusConflict)
+				return
+			}
+			newEmail = email
+			updates["pending_email"] = email
+		} else if user.PendingEmail != "" {
+			// changing back to the current address cancels the pending change
+			updates["pending_email"] = ""
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(&user).Updates(updates).Error; err != nil {
+			http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+			return
+		}
+		if err := database.DB.First(&user, uid).Error; err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	if newEmail != "" {
+		if err := sendEmailVerification(r.Context(), user, newEmail); err != nil {
+			log.Printf("failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}
+	logAudit(r, "account.profile_updated", "user", uid, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profileResponse(user))
+}
+
+// Avatar handles GET, PUT and DELETE /api/profile/avatar
+func Avatar(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getAvatar(w, r)
+	case http.MethodPut, http.MethodPost:
+		uploadAvatar(w, r)
+	case http.MethodDelete:
+		deleteAvatar(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func uploadAvatar(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarBytes+(64<<10))
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		http.Error(w, "Missing avatar file (max 2MB)", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAvatarBytes+1))
+	if err != nil {
+		http.Error(w, "Failed to read avatar", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxAvatarBytes {
+		http.Error(w, "Avatar must be at most 2MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// trust the bytes, not the client-supplied content type
+	contentType := http.DetectContentType(data)
+	ext, allowed := avatarExtensions[contentType]
+	if !allowed {
+		http.Error(w, "Avatar must be a PNG, JPEG, GIF or WebP image", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, uid).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	key := fmt.Sprintf("avatars/%d-%d.%s", user.ID, time.Now().UnixNano(), ext)
+	if err := storage.Default.Put(r.Context(), key, bytes.NewReader(data)); err != nil {
+		http.Error(w, "Failed to store avatar", http.StatusInternalServerError)
+		return
+	}
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{"avatar_key": key, "avatar_type": contentType}).Error; err != nil {
+		storage.Default.Delete(r.Context(), key)
+		http.Error(w, "Failed to save avatar", http.StatusInternalServerError)
+		return
+	}
+	if user.AvatarKey != "" {
+		if err := storage.Default.Delete(r.Context(), user.AvatarKey); err != nil {
+			log.Printf("failed to delete old avatar %s: %v", user.AvatarKey, err)
+		}
+	}
+	logAudit(r, "account.avatar_updated", "user", uid, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Avatar updated"})
+}
+
+func getAvatar(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, uid).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if user.AvatarKey == "" {
+		http.Error(w, "No avatar", http.StatusNotFound)
+		return
+	}
+
+	rc, err := storage.Default.Get(r.Context(), user.AvatarKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "No avatar", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read avatar", http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", user.AvatarType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.Copy(w, rc)
+}
+
+func deleteAvatar(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, uid).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if user.AvatarKey != "" {
+		if err := storage.Default.Delete(r.Context(), user.AvatarKey); err != nil {
+			http.Error(w, "Failed to delete avatar", http.StatusInternalServerError)
+			return
+		}
+		if err := database.DB.Model(&user).Updates(map[string]interface{}{"avatar_key": "", "avatar_type": ""}).Error; err != nil {
+			http.Error(w, "Failed to delete avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+	logAudit(r, "account.avatar_removed", "user", uid, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Avatar removed"})
+}
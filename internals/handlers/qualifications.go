@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+const maxQualificationLen = 50
+
+// qualifiedSlotSQL keeps events the user (the single bind arg) may own:
+// team-less events, or team events whose required tags are all held by the
+// user's membership in that team.
+const qualifiedSlotSQL = `(events.team_id IS NULL OR COALESCE(events.required_qualifications, '{}') <@ COALESCE(
+	(SELECT tm.qualifications FROM team_memberships tm WHERE tm.team_id = events.team_id AND tm.user_id = ?), '{}'))`
+
+// normalizeQualifications lowercases, trims and de-duplicates tags.
+func normalizeQualifications(tags []string) (pq.StringArray, error) {
+	seen := map[string]bool{}
+	out := pq.StringArray{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		if len(t) > maxQualificationLen || strings.ContainsAny(t, `{},"\`) {
+			return nil, fmt.Errorf("invalid qualification %q", t)
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// missingQualifications returns the tags from required the user lacks in
+// the event's team.
+func missingQualifications(db *gorm.DB, userID uint, event models.Event) ([]string, error) {
+	if event.TeamID == nil || len(event.RequiredQualifications) == 0 {
+		return nil, nil
+	}
+	var m models.TeamMembership
+	err := db.Where("team_id = ? AND user_id = ?", *event.TeamID, userID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return event.RequiredQualifications, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	held := map[string]bool{}
+	for _, q := range m.Qualifications {
+		held[q] = true
+	}
+	var missing []string
+	for _, q := range event.RequiredQualifications {
+		if !held[q] {
+			missing = append(missing, q)
+		}
+	}
+	return missing, nil
+}
+
+// checkSwapQualifications makes sure each side of a swap is qualified for the
+// slot they would end up owning.
+func checkSwapQualifications(mySlot, theirSlot models.Event) error {
+	checks := []struct {
+		who  uint
+		slot models.Event
+	}{
+		{mySlot.OwnerID(), theirSlot},
+		{theirSlot.OwnerID(), mySlot},
+	}
+	for _, c := range checks {
+		missing, err := missingQualifications(database.DB, c.who, c.slot)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("user %d lacks qualifications for %q: %s", c.who, c.slot.Title, strings.Join(missing, ", "))
+		}
+	}
+	return nil
+}
+
+// MemberQualifications handles PUT /api/teams/{id}/members/{userId}/qualifications
+func MemberQualifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+	memberID, err := pathID(r, "userId")
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if !requireTeamPermission(w, uid, teamID, access.GrantQualifications) {
+		return
+	}
+
+	var input struct {
+		Qualifications []string `json:"qualifications"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	tags, err := normalizeQualifications(input.Qualifications)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var membership models.TeamMembership
+	if err := database.DB.Where("team_id = ? AND user_id = ?", teamID, memberID).First(&membership).Error; err != nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	membership.Qualifications = tags
+	if err := database.DB.Model(&membership).Update("qualifications", tags).Error; err != nil {
+		http.Error(w, "Failed to update qualifications", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "team.member_qualifications_updated", "team", teamID, models.AuditDetails{"userId": memberID, "qualifications": []string(tags)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(membership)
+}
+
+// EventQualifications handles PUT /api/events/{id}/qualifications
+// Only team events can require qualifications, and the current owner must
+// already hold them.
+func EventQualifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	eventID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		Qualifications []string `json:"qualifications"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	tags, err := normalizeQualifications(input.Qualifications)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var event models.Event
+	if err := database.DB.First(&event, eventID).Error; err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+	if event.TeamID == nil {
+		http.Error(w, "Only team events can require qualifications", http.StatusBadRequest)
+		return
+	}
+	if !requireTeamPermission(w, uid, *event.TeamID, access.ManageEvents) {
+		return
+	}
+
+	before := event
+	event.RequiredQualifications = tags
+	if event.UserID != nil {
+		missing, err := missingQualifications(database.DB, *event.UserID, event)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if len(missing) > 0 {
+			http.Error(w, "Current owner lacks: "+strings.Join(missing, ", "), http.StatusConflict)
+			return
+		}
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&event).Update("required_qualifications", tags).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, before, event, revUpdated, &uid, nil)
+	})
+	if err != nil {
+		http.Error(w, "Failed to update event", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "event.qualifications_updated", "event", event.ID, models.AuditDetails{"qualifications": []string(tags)})
+	emitEventChanged(r, event, "qualifications_updated")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// pathID parses a numeric {name} wildcard from the route pattern.
+func pathID(r *http.Request, name string) (uint, error) {
+	v := r.PathValue(name)
+	if v == "" {
+		return 0, errors.New("missing " + name)
+	}
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
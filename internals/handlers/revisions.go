@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+// Revision actions.
+const (
+	revCreated    = "created"
+	revGenerated  = "generated"
+	revUpdated    = "updated"
+	revDeleted    = "deleted"
+	revRestored   = "restored"
+	revLocked     = "swap_requested"
+	revReleased   = "swap_released"
+	revSwapped    = "swapped"
+	revReverted   = "swap_reverted"
+	revReassigned = "reassigned"
+	revClaimed    = "claimed"
+	revOpened     = "opened"
+)
+
+// eventFields is the tracked state of an event, keyed by its JSON name.
+func eventFields(e models.Event) map[string]interface{} {
+	fields := map[string]interface{}{
+		"title":                  e.Title,
+		"startTime":              e.StartTime.UTC(),
+		"endTime":                e.EndTime.UTC(),
+		"status":                 e.Status,
+		"userId":                 e.UserID,
+		"teamId":                 e.TeamID,
+		"requiredQualifications": []string(e.RequiredQualifications),
+		"deleted":                e.DeletedAt.Valid,
+	}
+	if len(e.RequiredQualifications) == 0 {
+		fields["requiredQualifications"] = []string{}
+	}
+	return fields
+}
+
+// diffEvents returns the fields that differ between before and after.
+func diffEvents(before, after models.Event) map[string]models.FieldChange {
+	old, cur := eventFields(before), eventFields(after)
+	changes := map[string]models.FieldChange{}
+	for k, v := range cur {
+		a, _ := json.Marshal(old[k])
+		b, _ := json.Marshal(v)
+		if string(a) != string(b) {
+			changes[k] = models.FieldChange{Old: a, New: b}
+		}
+	}
+	return changes
+}
+
+// recordRevision stores what changed on the event. Nothing is written if
+// the tracked fields are unchanged. actorID is nil for system changes.
+func recordRevision(tx *gorm.DB, before, after models.Event, action string, actorID, swapID *uint) error {
+	changes := diffEvents(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+	return tx.Create(&models.EventRevision{
+		EventID: after.ID,
+		ActorID: actorID,
+		SwapID:  swapID,
+		Action:  action,
+		Changes: changes,
+	}).Error
+}
+
+// saveEvent writes the event and records how it differs from before.
+func saveEvent(tx *gorm.DB, before models.Event, event *models.Event, action string, actorID, swapID *uint) error {
+	if err := tx.Save(event).Error; err != nil {
+		return err
+	}
+	return recordRevision(tx, before, *event, action, actorID, swapID)
+}
+
+// recordCreation stores the initial state of a new event.
+func recordCreation(tx *gorm.DB, event models.Event, action string, actorID *uint) error {
+	return recordRevision(tx, models.Event{}, event, action, actorID, nil)
+}
+
+// canViewHistory lets the owner, team members and anyone who traded the
+// slot see its history.
+func canViewHistory(uid uint, event models.Event) (bool, error) {
+	if event.OwnedBy(uid) {
+		return true, nil
+	}
+	if event.TeamID != nil {
+		if ok, err := access.Can(uid, *event.TeamID, access.ViewTeam); err == nil && ok {
+			return true, nil
+		}
+	}
+	var n int64
+	err := database.DB.Model(&models.SwapRequest{}).
+		Where("(my_slot_id = ? OR their_slot_id = ?) AND (requester_id = ? OR receiver_id = ?)", event.ID, event.ID, uid, uid).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// originalOwner works out who held the event first: the owner it was
+// created with, or the previous owner in the earliest recorded reassignment
+// for events that predate revision tracking.
+func originalOwner(event models.Event, revisions []models.EventRevision) *uint {
+	for _, rev := range revisions {
+		change, ok := rev.Changes["userId"]
+		if !ok {
+			continue
+		}
+		value := change.Old
+		if rev.Action == revCreated || rev.Action == revGenerated {
+			value = change.New
+		}
+		var owner *uint
+		if err := json.Unmarshal(value, &owner); err != nil {
+			return nil
+		}
+		return owner
+	}
+	return event.UserID
+}
+
+// EventHistory handles GET /api/events/{id}/history
+// Revisions come oldest first, so the first one shows who was originally
+// on the shift.
+func EventHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	eventID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	var event models.Event
+	if err := database.DB.Unscoped().First(&event, eventID).Error; err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+	allowed, err := canViewHistory(uid, event)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+
+	var revisions []models.EventRevision
+	if err := database.DB.Where("event_id = ?", eventID).Order("created_at, id").Find(&revisions).Error; err != nil {
+		http.Error(w, "Error fetching history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"event":           event,
+		"revisions":       revisions,
+		"originalOwnerId": originalOwner(event, revisions),
+	})
+}
@@ -0,0 +1,565 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/rotation"
+)
+
+const maxRotationWeeks = 52
+
+func rotationDefinition(rot models.Rotation) rotation.Definition {
+	members := make([]uint, 0, len(rot.MemberIDs))
+	for _, id := range rot.MemberIDs {
+		members = append(members, uint(id))
+	}
+	return rotation.Definition{
+		Members:    members,
+		ShiftHours: rot.ShiftHours,
+		StartDate:  rot.StartDate,
+		Handoff:    rot.HandoffTime,
+		TimeZone:   rot.TimeZone,
+	}
+}
+
+// clearFutureRotationEvents removes the rotation's upcoming events that
+// nobody has touched. Slots that were ever part of a swap request, or were
+// changed in any way since they were generated, are kept. Removed slots keep
+// their history, closed with a deleted revision.
+func clearFutureRotationEvents(tx *gorm.DB, rot models.Rotation, now time.Time) error {
+	swappedOut := tx.Model(&models.SwapRequest{}).Select("my_slot_id")
+	swappedIn := tx.Model(&models.SwapRequest{}).Select("their_slot_id")
+	changed := tx.Model(&models.EventRevision{}).Select("event_id").Where("action <> ?", revGenerated)
+
+	var events []models.Event
+	if err := tx.
+		Where("rotation_id = ? AND start_time > ?", rot.ID, now).
+		Where("(status = ? AND user_id IS NULL) OR (status = ? AND user_id = rotation_assignee_id)", models.SlotOpen, models.SlotBusy).
+		Where("id NOT IN (?) AND id NOT IN (?) AND id NOT IN (?)", swappedOut, swappedIn, changed).
+		Find(&events).Error; err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	if err := tx.Where("event_id IN ?", ids).Delete(&models.ShiftClaim{}).Error; err != nil {
+		return err
+	}
+	// generated slots never reached anyone, so they skip the trash
+	if err := tx.Unscoped().Where("id IN ?", ids).Delete(&models.Event{}).Error; err != nil {
+		return err
+	}
+	for _, e := range events {
+		after := e
+		after.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		if err := recordRevision(tx, e, after, revDeleted, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropRotationMember takes the user out of the team's rotations and
+// regenerates them, so the user's untouched upcoming shifts go to the next
+// member in line. A rotation left without members stops generating.
+func dropRotationMember(tx *gorm.DB, teamID, userID uint) error {
+	var rots []models.Rotation
+	if err := tx.Where("team_id = ? AND ? = ANY(member_ids)", teamID, userID).Find(&rots).Error; err != nil {
+		return err
+	}
+	for _, rot := range rots {
+		rot.MemberIDs = slices.DeleteFunc(rot.MemberIDs, func(id int64) bool { return id == int64(userID) })
+		if err := tx.Model(&rot).Update("member_ids", rot.MemberIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("rotation_id = ? AND user_id = ?", rot.ID, userID).Delete(&models.RotationExclusion{}).Error; err != nil {
+			return err
+		}
+		if err := syncRotation(tx, rot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncRotation regenerates the rotation's events from now until its
+// horizon, leaving preserved slots alone.
+func syncRotation(tx *gorm.DB, rot models.Rotation) error {
+	now := time.Now()
+	if err := clearFutureRotationEvents(tx, rot, now); err != nil {
+		return err
+	}
+	if len(rot.MemberIDs) == 0 {
+		return nil
+	}
+
+	var exclusions []models.RotationExclusion
+	if err := tx.Where("rotation_id = ?", rot.ID).Find(&exclusions).Error; err != nil {
+		return err
+	}
+	excl := make([]rotation.Exclusion, 0, len(exclusions))
+	for _, e := range exclusions {
+		excl = append(excl, rotation.Exclusion{UserID: e.UserID, Start: e.StartTime, End: e.EndTime})
+	}
+	// members' leave counts as an exclusion too
+	var blocks []models.AvailabilityBlock
+	if err := tx.Where("user_id IN ? AND end_time > ?", []int64(rot.MemberIDs), now).Find(&blocks).Error; err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		excl = append(excl, rotation.Exclusion{UserID: b.UserID, Start: b.StartTime, End: b.EndTime})
+	}
+
+	slots, err := rotationDefinition(rot).Slots(now, now.AddDate(0, 0, 7*rot.WeeksAhead), excl)
+	if err != nil {
+		return err
+	}
+
+	// trashed slots count as kept so deleting one isn't undone here
+	var kept []models.Event
+	if err := tx.Unscoped().Where("rotation_id = ? AND start_time > ?", rot.ID, now).Find(&kept).Error; err != nil {
+		return err
+	}
+	taken := map[int64]bool{}
+	for _, e := range kept {
+		taken[e.StartTime.Unix()] = true
+	}
+
+	var events []models.Event
+	for _, s := range slots {
+		if taken[s.Start.Unix()] {
+			continue
+		}
+		event := models.Event{
+			Title:              rot.Name,
+			StartTime:          s.Start,
+			EndTime:            s.End,
+			Status:             models.SlotBusy,
+			UserID:             s.UserID,
+			TeamID:             &rot.TeamID,
+			RotationID:         &rot.ID,
+			RotationAssigneeID: s.UserID,
+		}
+		// nobody is available: put the shift in the open pool
+		if s.UserID == nil {
+			event.Status = models.SlotOpen
+			event.ClaimMode = models.ClaimFirstCome
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	if err := tx.CreateInBatches(&events, 100).Error; err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := recordCreation(tx, e, revGenerated, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type rotationInput struct {
+	Name        string `json:"name"`
+	MemberIDs   []uint `json:"memberIds"`
+	ShiftHours  int    `json:"shiftHours"`
+	HandoffTime string `json:"handoffTime"`
+	TimeZone    string `json:"timeZone"`
+	StartDate   string `json:"startDate"`
+	WeeksAhead  int    `json:"weeksAhead"`
+}
+
+// apply validates the input and copies it onto rot.
+func (in rotationInput) apply(rot *models.Rotation) error {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return errors.New("name is required")
+	}
+	if in.TimeZone == "" {
+		in.TimeZone = "UTC"
+	}
+	if in.WeeksAhead == 0 {
+		in.WeeksAhead = 4
+	}
+	if in.WeeksAhead < 0 || in.WeeksAhead > maxRotationWeeks {
+		return errors.New("weeksAhead must be between 1 and 52")
+	}
+	for _, id := range in.MemberIDs {
+		member, err := isTeamMember(database.DB, id, rot.TeamID)
+		if err != nil {
+			return err
+		}
+		if !member {
+			return errors.New("every rotation member must belong to the team")
+		}
+	}
+
+	members := make(pq.Int64Array, 0, len(in.MemberIDs))
+	for _, id := range in.MemberIDs {
+		members = append(members, int64(id))
+	}
+	rot.Name = in.Name
+	rot.MemberIDs = members
+	rot.ShiftHours = in.ShiftHours
+	rot.HandoffTime = in.HandoffTime
+	rot.TimeZone = in.TimeZone
+	rot.StartDate = in.StartDate
+	rot.WeeksAhead = in.WeeksAhead
+	return rotationDefinition(*rot).Validate()
+}
+
+// loadRotation fetches the rotation and checks the caller's permission in
+// its team, writing the error response if either fails.
+func loadRotation(w http.ResponseWriter, r *http.Request, uid uint, perm access.Permission) (models.Rotation, bool) {
+	var rot models.Rotation
+	rotationID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid rotation ID", http.StatusBadRequest)
+		return rot, false
+	}
+	if err := database.DB.First(&rot, rotationID).Error; err != nil {
+		http.Error(w, "Rotation not found", http.StatusNotFound)
+		return rot, false
+	}
+	if !requireTeamPermission(w, uid, rot.TeamID, perm) {
+		return rot, false
+	}
+	return rot, true
+}
+
+// Rotations handles GET and POST /api/teams/{id}/rotations
+// POST creates a rotation and generates its events.
+func Rotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		if !requireTeamPermission(w, uid, teamID, access.ViewTeam) {
+			return
+		}
+		var rotations []models.Rotation
+		if err := database.DB.Where("team_id = ?", teamID).Order("name").Find(&rotations).Error; err != nil {
+			http.Error(w, "Error fetching rotations", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rotations)
+		return
+	}
+
+	if !requireTeamPermission(w, uid, teamID, access.ManageEvents) {
+		return
+	}
+	var input rotationInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	rot := models.Rotation{TeamID: teamID, CreatedByID: uid}
+	if err := input.apply(&rot); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rot).Error; err != nil {
+			return err
+		}
+		return syncRotation(tx, rot)
+	})
+	if err != nil {
+		http.Error(w, "Failed to create rotation", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "rotation.created", "rotation", rot.ID, models.AuditDetails{"teamId": rot.TeamID})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rot)
+}
+
+// RotationDetail handles GET, PUT and DELETE /api/rotations/{id}
+// PUT regenerates the upcoming events; DELETE removes the untouched ones.
+func RotationDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	perm := access.ViewTeam
+	if r.Method != http.MethodGet {
+		perm = access.ManageEvents
+	}
+	rot, ok := loadRotation(w, r, uid, perm)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var input rotationInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := input.apply(&rot); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&rot).Error; err != nil {
+				return err
+			}
+			return syncRotation(tx, rot)
+		})
+		if err != nil {
+			http.Error(w, "Failed to update rotation", http.StatusInternalServerError)
+			return
+		}
+		logAudit(r, "rotation.updated", "rotation", rot.ID, nil)
+
+	case http.MethodDelete:
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := clearFutureRotationEvents(tx, rot, time.Now()); err != nil {
+				return err
+			}
+			// past and preserved slots stay as ordinary events
+			if err := tx.Model(&models.Event{}).Where("rotation_id = ?", rot.ID).
+				Updates(map[string]interface{}{"rotation_id": nil, "rotation_assignee_id": nil}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("rotation_id = ?", rot.ID).Delete(&models.RotationExclusion{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&rot).Error
+		})
+		if err != nil {
+			http.Error(w, "Failed to delete rotation", http.StatusInternalServerError)
+			return
+		}
+		logAudit(r, "rotation.deleted", "rotation", rot.ID, nil)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Rotation deleted"})
+		return
+
+	default:
+		if err := database.DB.Where("rotation_id = ?", rot.ID).Order("start_time").Find(&rot.Exclusions).Error; err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rot)
+}
+
+// GenerateRotation handles POST /api/rotations/{id}/generate
+// It extends (or shortens) the horizon to the given number of weeks.
+func GenerateRotation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rot, ok := loadRotation(w, r, uid, access.ManageEvents)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Weeks int `json:"weeks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if input.Weeks < 1 || input.Weeks > maxRotationWeeks {
+		http.Error(w, "weeks must be between 1 and 52", http.StatusBadRequest)
+		return
+	}
+
+	rot.WeeksAhead = input.Weeks
+	var events []models.Event
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&rot).Update("weeks_ahead", rot.WeeksAhead).Error; err != nil {
+			return err
+		}
+		if err := syncRotation(tx, rot); err != nil {
+			return err
+		}
+		return tx.Where("rotation_id = ? AND end_time > ?", rot.ID, time.Now()).Order("start_time").Find(&events).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to generate rotation", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "rotation.generated", "rotation", rot.ID, models.AuditDetails{"weeksAhead": rot.WeeksAhead})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// RotationExclusions handles POST /api/rotations/{id}/exclusions
+func RotationExclusions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rot, ok := loadRotation(w, r, uid, access.ManageEvents)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		UserID    uint   `json:"userId"`
+		StartTime string `json:"startTime"`
+		EndTime   string `json:"endTime"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse(time.RFC3339, input.StartTime)
+	if err != nil {
+		http.Error(w, "Invalid startTime format", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse(time.RFC3339, input.EndTime)
+	if err != nil {
+		http.Error(w, "Invalid endTime format", http.StatusBadRequest)
+		return
+	}
+	if !end.After(start) {
+		http.Error(w, "endTime must be after startTime", http.StatusBadRequest)
+		return
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if len(input.Reason) > 200 {
+		http.Error(w, "reason must be at most 200 characters", http.StatusBadRequest)
+		return
+	}
+	inRotation := false
+	for _, id := range rot.MemberIDs {
+		if uint(id) == input.UserID {
+			inRotation = true
+		}
+	}
+	if !inRotation {
+		http.Error(w, "userId is not in this rotation", http.StatusBadRequest)
+		return
+	}
+
+	exclusion := models.RotationExclusion{
+		RotationID: rot.ID,
+		UserID:     input.UserID,
+		StartTime:  start,
+		EndTime:    end,
+		Reason:     input.Reason,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&exclusion).Error; err != nil {
+			return err
+		}
+		return syncRotation(tx, rot)
+	})
+	if err != nil {
+		http.Error(w, "Failed to add exclusion", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "rotation.exclusion_created", "rotation", rot.ID, models.AuditDetails{"exclusionId": exclusion.ID, "userId": exclusion.UserID})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(exclusion)
+}
+
+// DeleteRotationExclusion handles DELETE /api/rotations/{id}/exclusions/{exclusionId}
+func DeleteRotationExclusion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rot, ok := loadRotation(w, r, uid, access.ManageEvents)
+	if !ok {
+		return
+	}
+	exclusionID, err := pathID(r, "exclusionId")
+	if err != nil {
+		http.Error(w, "Invalid exclusion ID", http.StatusBadRequest)
+		return
+	}
+
+	var found bool
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND rotation_id = ?", exclusionID, rot.ID).Delete(&models.RotationExclusion{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if found = res.RowsAffected > 0; !found {
+			return nil
+		}
+		return syncRotation(tx, rot)
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete exclusion", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Exclusion not found", http.StatusNotFound)
+		return
+	}
+	logAudit(r, "rotation.exclusion_deleted", "rotation", rot.ID, models.AuditDetails{"exclusionId": exclusionID})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Exclusion deleted"})
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/bus"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+const maxSavedSearches = 20
+
+// wallMinutes is t's time of day in minutes.
+func wallMinutes(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+// searchMatches reports whether the slot meets every field set on the
+// search, reading weekdays and times in loc.
+func searchMatches(s models.SavedSearch, e models.Event, loc *time.Location) bool {
+	if s.TeamID != nil && (e.TeamID == nil || *e.TeamID != *s.TeamID) {
+		return false
+	}
+	if s.Keyword != "" && !strings.Contains(strings.ToLower(e.Title), strings.ToLower(s.Keyword)) {
+		return false
+	}
+	start, end := e.StartTime.In(loc), e.EndTime.In(loc)
+	if len(s.Weekdays) > 0 && !slices.Contains(s.Weekdays, int64(start.Weekday())) {
+		return false
+	}
+	if s.StartTime != "" {
+		from, err := time.Parse("15:04", s.StartTime)
+		if err != nil || wallMinutes(start) < wallMinutes(from) {
+			return false
+		}
+	}
+	if s.EndTime != "" {
+		to, err := time.Parse("15:04", s.EndTime)
+		sameDay := end.Year() == start.Year() && end.YearDay() == start.YearDay()
+		if err != nil || !sameDay || wallMinutes(end) > wallMinutes(to) {
+			return false
+		}
+	}
+	return true
+}
+
+// notifySavedSearches tells the users whose saved searches match a slot that
+// was just put up for swap. Each user hears about it once however many of
+// their searches match, and only if they are qualified to take it. Failures
+// are logged.
+func notifySavedSearches(r *http.Request, event models.Event) {
+	if event.Status != models.SlotSwappable {
+		return
+	}
+	query := database.DB.Where("saved_searches.user_id <> ?", event.OwnerID())
+	if event.TeamID != nil {
+		query = query.Joins("JOIN team_memberships ON team_memberships.user_id = saved_searches.user_id AND team_memberships.team_id = ?", *event.TeamID).
+			Where("saved_searches.team_id IS NULL OR saved_searches.team_id = ?", *event.TeamID)
+	} else {
+		query = query.Where("saved_searches.team_id IS NULL")
+	}
+	var searches []models.SavedSearch
+	if err := query.Order("saved_searches.id").Find(&searches).Error; err != nil {
+		log.Printf("failed to load saved searches for event %d: %v", event.ID, err)
+		return
+	}
+
+	notified := map[uint]bool{}
+	for _, s := range searches {
+		if notified[s.UserID] {
+			continue
+		}
+		var user models.User
+		if err := database.DB.First(&user, s.UserID).Error; err != nil || user.AnonymizedAt != nil {
+			continue
+		}
+		if !searchMatches(s, event, userLocation(user)) {
+			continue
+		}
+		if missing, err := missingQualifications(database.DB, s.UserID, event); err != nil || len(missing) > 0 {
+			continue
+		}
+		notified[s.UserID] = true
+		emit(r, bus.SlotMatched, models.JSONMap{
+			"eventId":    event.ID,
+			"teamId":     event.TeamID,
+			"title":      event.Title,
+			"startTime":  event.StartTime,
+			"endTime":    event.EndTime,
+			"searchId":   s.ID,
+			"searchName": s.Name,
+		}, s.UserID)
+	}
+}
+
+// SavedSearches handles GET and POST /api/saved-searches
+// POST saves a marketplace filter; the caller is notified whenever a
+// teammate puts up a slot that matches it.
+func SavedSearches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		searches := []models.SavedSearch{}
+		if err := database.DB.Where("user_id = ?", uid).Order("id").Find(&searches).Error; err != nil {
+			http.Error(w, "Error fetching saved searches", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(searches)
+		return
+	}
+
+	var input struct {
+		Name      string  `json:"name"`
+		TeamID    *uint   `json:"teamId"`
+		Keyword   string  `json:"keyword"`
+		Weekdays  []int64 `json:"weekdays"`
+		StartTime string  `json:"startTime"`
+		EndTime   string  `json:"endTime"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	input.Keyword = strings.TrimSpace(input.Keyword)
+	if input.Name == "" || len(input.Name) > 100 {
+		http.Error(w, "name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	if len(input.Keyword) > 100 {
+		http.Error(w, "keyword must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	for _, d := range input.Weekdays {
+		if d < 0 || d > 6 {
+			http.Error(w, "weekdays must be between 0 (Sunday) and 6", http.StatusBadRequest)
+			return
+		}
+	}
+	for _, v := range []string{input.StartTime, input.EndTime} {
+		if _, err := time.Parse("15:04", v); v != "" && err != nil {
+			http.Error(w, "startTime and endTime must be HH:MM", http.StatusBadRequest)
+			return
+		}
+	}
+	if input.TeamID != nil && !requireTeamPermission(w, uid, *input.TeamID, access.ViewTeam) {
+		return
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.SavedSearch{}).Where("user_id = ?", uid).Count(&count).Error; err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if count >= maxSavedSearches {
+		http.Error(w, "Too many saved searches", http.StatusConflict)
+		return
+	}
+
+	search := models.SavedSearch{
+		UserID:    uid,
+		Name:      input.Name,
+		TeamID:    input.TeamID,
+		Keyword:   input.Keyword,
+		Weekdays:  pq.Int64Array(input.Weekdays),
+		StartTime: input.StartTime,
+		EndTime:   input.EndTime,
+	}
+	if err := database.DB.Create(&search).Error; err != nil {
+		http.Error(w, "Failed to save search", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "search.created", "saved_search", search.ID, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(search)
+}
+
+// DeleteSavedSearch handles DELETE /api/saved-searches/{id}
+func DeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	searchID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid search ID", http.StatusBadRequest)
+		return
+	}
+
+	res := database.DB.Where("id = ? AND user_id = ?", searchID, uid).Delete(&models.SavedSearch{})
+	if res.Error != nil {
+		http.Error(w, "Failed to delete search", http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		http.Error(w, "Search not found", http.StatusNotFound)
+		return
+	}
+	logAudit(r, "search.deleted", "saved_search", searchID, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Search deleted"})
+}
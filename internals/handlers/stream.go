@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jfernsio/slotswapper/internals/bus"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+const (
+	streamHeartbeat   = 25 * time.Second
+	streamReplayBatch = 200
+)
+
+// writeSSE writes one notification as a server-sent event.
+func writeSSE(w http.ResponseWriter, n models.Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", n.ID, n.Type, data)
+	return err
+}
+
+// NotificationStream handles GET /api/notifications/stream
+// It keeps the connection open and pushes the caller's notifications as
+// server-sent events. A reconnecting client sends Last-Event-ID (or
+// ?lastEventId) and first receives everything it missed.
+func NotificationStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastParam := r.Header.Get("Last-Event-ID")
+	if lastParam == "" {
+		lastParam = r.URL.Query().Get("lastEventId")
+	}
+	var lastID uint
+	if lastParam != "" {
+		n, err := strconv.ParseUint(lastParam, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastID = uint(n)
+	}
+
+	// subscribe before replaying so nothing published in between is lost
+	sub := bus.Default.Subscribe(uid)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+
+	// notifications can commit and be published out of ID order, so the
+	// live channel is only checked against what the replay already sent
+	replayed := map[uint]bool{}
+	if lastID > 0 {
+		for {
+			var missed []models.Notification
+			if err := database.DB.Where("user_id = ? AND id > ?", uid, lastID).
+				Order("id").Limit(streamReplayBatch).Find(&missed).Error; err != nil {
+				return
+			}
+			for _, n := range missed {
+				if writeSSE(w, n) != nil {
+					return
+				}
+				replayed[n.ID] = true
+				lastID = n.ID
+			}
+			if len(missed) < streamReplayBatch {
+				break
+			}
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case n, ok := <-sub.C:
+			// a closed channel means we fell behind; the client reconnects
+			// with its last ID and catches up from the database
+			if !ok {
+				return
+			}
+			if replayed[n.ID] {
+				delete(replayed, n.ID)
+				continue
+			}
+			if writeSSE(w, n) != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
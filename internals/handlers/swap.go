@@ -2,11 +2,20 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/bus"
 	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
 	"github.com/jfernsio/slotswapper/internals/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ✅ 1️⃣ GET /api/swappable-slots
@@ -16,20 +25,62 @@ func GetSwappableSlots(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := r.Context().Value("user_id")
-	if userID == nil {
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	teamIDs, err := teamIDsForUser(uid)
+	if err != nil {
+		http.Error(w, "Error fetching swappable slots", http.StatusInternalServerError)
+		return
+	}
+
+	// only slots from the caller's teams, plus the team-less legacy market
+	query := database.DB.Where("user_id != ? AND status = ?", uid, models.SlotSwappable)
+	if teamParam := r.URL.Query().Get("teamId"); teamParam != "" {
+		teamID, err := strconv.ParseUint(teamParam, 10, 64)
+		if err != nil || !slices.Contains(teamIDs, uint(teamID)) {
+			http.Error(w, "Team not found", http.StatusNotFound)
+			return
+		}
+		query = query.Where("team_id = ?", teamID)
+	} else if len(teamIDs) > 0 {
+		query = query.Where("team_id IN ? OR team_id IS NULL", teamIDs)
+	} else {
+		query = query.Where("team_id IS NULL")
+	}
+
+	query = query.Where(qualifiedSlotSQL, uid).Where(unavailableSQL, uid)
 
 	var events []models.Event
-	if err := database.DB.
-		Where("user_id != ? AND status = ?", userID, models.SlotSwappable).
-		Find(&events).Error; err != nil {
+	if err := query.Find(&events).Error; err != nil {
 		http.Error(w, "Error fetching swappable slots", http.StatusInternalServerError)
 		return
 	}
 
+	// ?preferredHours=true narrows the market to the caller's working hours
+	if r.URL.Query().Get("preferredHours") == "true" {
+		var user models.User
+		var hours []models.PreferredHours
+		if err := database.DB.First(&user, uid).Error; err != nil {
+			http.Error(w, "Error fetching swappable slots", http.StatusInternalServerError)
+			return
+		}
+		if err := database.DB.Where("user_id = ?", uid).Find(&hours).Error; err != nil {
+			http.Error(w, "Error fetching swappable slots", http.StatusInternalServerError)
+			return
+		}
+		loc := userLocation(user)
+		filtered := events[:0]
+		for _, e := range events {
+			if withinPreferredHours(hours, loc, e.StartTime, e.EndTime) {
+				filtered = append(filtered, e)
+			}
+		}
+		events = filtered
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(events)
 }
@@ -68,30 +119,102 @@ func CreateSwapRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	uid, _ := middleware.UserIDFromContext(r.Context())
+	if !mySlot.OwnedBy(uid) {
+		http.Error(w, "You can only offer your own slot", http.StatusForbidden)
+		return
+	}
+	if !sameTeam(mySlot.TeamID, theirSlot.TeamID) {
+		http.Error(w, "Slots must belong to the same team", http.StatusBadRequest)
+		return
+	}
+	if theirSlot.TeamID != nil && !requireTeamPermission(w, uid, *theirSlot.TeamID, access.SwapSlots) {
+		return
+	}
+
 	// Verify both are swappable
 	if mySlot.Status != models.SlotSwappable || theirSlot.Status != models.SlotSwappable {
 		http.Error(w, "Both slots must be swappable", http.StatusBadRequest)
 		return
 	}
+	if err := checkSwapQualifications(mySlot, theirSlot); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkSwapAvailability(uid, mySlot, theirSlot); err != nil {
+		writeAvailabilityError(w, err)
+		return
+	}
+	if err := checkSwapPolicy(mySlot, theirSlot); err != nil {
+		writePolicyError(w, err)
+		return
+	}
+	if err := checkOpenRequestLimit(uid, mySlot.TeamID); err != nil {
+		writePolicyError(w, err)
+		return
+	}
+	violations, enforcement, err := checkSwapLaborRules(mySlot, theirSlot)
+	if err != nil {
+		http.Error(w, "Failed to check labor rules", http.StatusInternalServerError)
+		return
+	}
+	if len(violations) > 0 && enforcement == models.EnforceBlock {
+		writeViolations(w, violations)
+		return
+	}
 
 	swap := models.SwapRequest{
 		MySlotID:    input.MySlotID,
 		TheirSlotID: input.TheirSlotID,
-		RequesterID: mySlot.UserID,
-		ReceiverID:  theirSlot.UserID,
+		RequesterID: mySlot.OwnerID(),
+		ReceiverID:  theirSlot.OwnerID(),
 		Status:      models.SwapPending,
 	}
 
-	if err := database.DB.Create(&swap).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// re-read under row locks so two requests can't both take the slots
+		var slots []models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []uint{mySlot.ID, theirSlot.ID}).Order("id").Find(&slots).Error; err != nil {
+			return err
+		}
+		if len(slots) != 2 {
+			return errSlotsUnavailable
+		}
+		for _, s := range slots {
+			read := mySlot
+			if s.ID == theirSlot.ID {
+				read = theirSlot
+			}
+			if s.Status != models.SlotSwappable || s.OwnerID() != read.OwnerID() {
+				return errSlotsUnavailable
+			}
+		}
+		if err := tx.Create(&swap).Error; err != nil {
+			return err
+		}
+		// Lock both slots
+		myBefore, theirBefore := mySlot, theirSlot
+		mySlot.Status = models.SlotSwapPending
+		theirSlot.Status = models.SlotSwapPending
+		if err := saveEvent(tx, myBefore, &mySlot, revLocked, &uid, &swap.ID); err != nil {
+			return err
+		}
+		return saveEvent(tx, theirBefore, &theirSlot, revLocked, &uid, &swap.ID)
+	})
+	if err == errSlotsUnavailable {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
 		http.Error(w, "Failed to create swap request", http.StatusInternalServerError)
 		return
 	}
+	swap.Warnings = violations
 
-	// Lock both slots
-	mySlot.Status = models.SlotSwapPending
-	theirSlot.Status = models.SlotSwapPending
-	database.DB.Save(&mySlot)
-	database.DB.Save(&theirSlot)
+	logAudit(r, "swap.requested", "swap", swap.ID, models.AuditDetails{"mySlotId": swap.MySlotID, "theirSlotId": swap.TheirSlotID})
+	emitSwap(r, bus.SwapCreated, swap)
+	publishSlots(mySlot, theirSlot)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(swap)
@@ -127,36 +250,243 @@ func RespondToSwap(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	uid, _ := middleware.UserIDFromContext(r.Context())
+
 	var swap models.SwapRequest
-	if err := database.DB.First(&swap, swapID).Error; err != nil {
+	if err := database.DB.First(&swap, swapID).Error; err != nil || swap.ReceiverID != uid {
 		http.Error(w, "Swap request not found", http.StatusNotFound)
 		return
 	}
+	if swap.Status != models.SwapPending {
+		http.Error(w, "Swap request is no longer pending", http.StatusConflict)
+		return
+	}
 
 	var mySlot, theirSlot models.Event
-	database.DB.First(&mySlot, swap.MySlotID)
-	database.DB.First(&theirSlot, swap.TheirSlotID)
+	if err := database.DB.First(&mySlot, swap.MySlotID).Error; err != nil {
+		http.Error(w, "Slot not found", http.StatusNotFound)
+		return
+	}
+	if err := database.DB.First(&theirSlot, swap.TheirSlotID).Error; err != nil {
+		http.Error(w, "Slot not found", http.StatusNotFound)
+		return
+	}
 
+	// qualifications may have changed since the request was made
 	if input.Accept {
-		swap.Status = models.SwapAccepted
-
-		// Swap ownership
-		temp := mySlot.UserID
-		mySlot.UserID = theirSlot.UserID
-		theirSlot.UserID = temp
+		if err := checkSwapQualifications(mySlot, theirSlot); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err := checkSwapAvailability(uid, mySlot, theirSlot); err != nil {
+			writeAvailabilityError(w, err)
+			return
+		}
+		// a slot may have entered the lead time or a blackout while pending
+		if err := checkSwapPolicy(mySlot, theirSlot); err != nil {
+			writePolicyError(w, err)
+			return
+		}
+	}
 
-		mySlot.Status = models.SlotBusy
-		theirSlot.Status = models.SlotBusy
-	} else {
-		swap.Status = models.SwapRejected
-		mySlot.Status = models.SlotSwappable
-		theirSlot.Status = models.SlotSwappable
+	needsApproval, err := swapNeedsApproval(theirSlot)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if input.Accept {
+		violations, enforcement, err := checkSwapLaborRules(mySlot, theirSlot)
+		if err != nil {
+			http.Error(w, "Failed to check labor rules", http.StatusInternalServerError)
+			return
+		}
+		if len(violations) > 0 && enforcement == models.EnforceBlock {
+			writeViolations(w, violations)
+			return
+		}
+		// warnings need a manager to override them by approving
+		if len(violations) > 0 {
+			needsApproval = true
+			swap.Warnings = violations
+		}
 	}
 
-	database.DB.Save(&swap)
-	database.DB.Save(&mySlot)
-	database.DB.Save(&theirSlot)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockSwap(tx, swap, &mySlot, &theirSlot, models.SwapPending); err != nil {
+			return err
+		}
+		switch {
+		case input.Accept && needsApproval:
+			// slots stay locked until a manager approves, see DecideSwap
+			swap.Status = models.SwapAwaitingApproval
+			return tx.Save(&swap).Error
+		case input.Accept:
+			return completeSwap(tx, uid, &swap, &mySlot, &theirSlot)
+		default:
+			return releaseSwap(tx, uid, &swap, &mySlot, &theirSlot, models.SwapRejected, "")
+		}
+	})
+	if errors.Is(err, errSwapDecided) || errors.Is(err, errSlotsUnavailable) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to respond to swap", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, swapOutcome(swap.Status), "swap", swap.ID, nil)
+	emitSwap(r, swapOutcome(swap.Status), swap)
+	publishSlots(mySlot, theirSlot)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(swap)
 }
+
+// swapOutcome names the event for the state a response left the swap in.
+func swapOutcome(status models.SwapStatus) string {
+	switch status {
+	case models.SwapAccepted:
+		return bus.SwapAccepted
+	case models.SwapAwaitingApproval:
+		return bus.SwapAwaitingApproval
+	default:
+		return bus.SwapRejected
+	}
+}
+
+// swapNeedsApproval reports whether the slot's team requires a manager to
+// sign off accepted swaps.
+func swapNeedsApproval(slot models.Event) (bool, error) {
+	if slot.TeamID == nil {
+		return false, nil
+	}
+	var team models.Team
+	if err := database.DB.First(&team, *slot.TeamID).Error; err != nil {
+		return false, err
+	}
+	return team.RequireSwapApproval, nil
+}
+
+// completeSwap exchanges ownership of the two slots and marks the swap accepted.
+func completeSwap(tx *gorm.DB, actorID uint, swap *models.SwapRequest, mySlot, theirSlot *models.Event) error {
+	now := time.Now()
+	swap.Status = models.SwapAccepted
+	swap.DecidedAt = &now
+
+	myBefore, theirBefore := *mySlot, *theirSlot
+	mySlot.UserID, theirSlot.UserID = theirSlot.UserID, mySlot.UserID
+	mySlot.Status = models.SlotBusy
+	theirSlot.Status = models.SlotBusy
+
+	if err := tx.Save(swap).Error; err != nil {
+		return err
+	}
+	if err := saveEvent(tx, myBefore, mySlot, revSwapped, &actorID, &swap.ID); err != nil {
+		return err
+	}
+	return saveEvent(tx, theirBefore, theirSlot, revSwapped, &actorID, &swap.ID)
+}
+
+// releaseSwap closes the swap with status and puts both slots back on the market.
+func releaseSwap(tx *gorm.DB, actorID uint, swap *models.SwapRequest, mySlot, theirSlot *models.Event, status models.SwapStatus, reason string) error {
+	now := time.Now()
+	swap.Status = status
+	swap.DecidedAt = &now
+	swap.Reason = reason
+
+	myBefore, theirBefore := *mySlot, *theirSlot
+	mySlot.Status = models.SlotSwappable
+	theirSlot.Status = models.SlotSwappable
+
+	if err := tx.Save(swap).Error; err != nil {
+		return err
+	}
+	if err := saveEvent(tx, myBefore, mySlot, revReleased, &actorID, &swap.ID); err != nil {
+		return err
+	}
+	return saveEvent(tx, theirBefore, theirSlot, revReleased, &actorID, &swap.ID)
+}
+
+// openSwapStatuses are the states in which a swap still holds its slots.
+var openSwapStatuses = []models.SwapStatus{models.SwapPending, models.SwapAwaitingApproval}
+
+var (
+	errSlotsUnavailable = errors.New("slots are no longer swappable")
+	errSwapDecided      = errors.New("swap request was decided in the meantime")
+)
+
+// lockSwap re-reads the swap and both of its slots under row locks and checks
+// that nothing changed since the caller read them: the swap is still in
+// status and both slots are still locked for it by the same owners. The
+// locked slot rows replace mySlot and theirSlot.
+func lockSwap(tx *gorm.DB, swap models.SwapRequest, mySlot, theirSlot *models.Event, status models.SwapStatus) error {
+	var locked models.SwapRequest
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, swap.ID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errSwapDecided
+	}
+	if err != nil {
+		return err
+	}
+	if locked.Status != status {
+		return errSwapDecided
+	}
+	var slots []models.Event
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", []uint{swap.MySlotID, swap.TheirSlotID}).Order("id").Find(&slots).Error; err != nil {
+		return err
+	}
+	if len(slots) != 2 {
+		return errSlotsUnavailable
+	}
+	for _, s := range slots {
+		read := mySlot
+		if s.ID == swap.TheirSlotID {
+			read = theirSlot
+		}
+		if s.Status != models.SlotSwapPending || s.OwnerID() != read.OwnerID() {
+			return errSlotsUnavailable
+		}
+		*read = s
+	}
+	return nil
+}
+
+// cancelPendingSwaps cancels the open swaps matching the condition and puts
+// both slots back on the market. It returns the slots it released and the
+// swaps it cancelled, for the caller to announce with emitCancelledSwaps
+// once the transaction commits.
+func cancelPendingSwaps(tx *gorm.DB, actorID uint, cond string, args ...interface{}) ([]models.Event, []models.SwapRequest, error) {
+	// locked so a concurrent accept or decision either finishes first and
+	// drops the swap from the match, or waits for the cancel
+	var swaps []models.SwapRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status IN ?", openSwapStatuses).Where(cond, args...).Order("id").Find(&swaps).Error; err != nil {
+		return nil, nil, err
+	}
+	var released []models.Event
+	for n := range swaps {
+		swap := &swaps[n]
+		var slots []models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND status = ?", []uint{swap.MySlotID, swap.TheirSlotID}, models.SlotSwapPending).
+			Order("id").Find(&slots).Error; err != nil {
+			return nil, nil, err
+		}
+		for i := range slots {
+			before := slots[i]
+			slots[i].Status = models.SlotSwappable
+			if err := saveEvent(tx, before, &slots[i], revReleased, &actorID, &swap.ID); err != nil {
+				return nil, nil, err
+			}
+		}
+		now := time.Now()
+		swap.Status = models.SwapCancelled
+		swap.DecidedAt = &now
+		if err := tx.Model(swap).Updates(map[string]interface{}{"status": swap.Status, "decided_at": now}).Error; err != nil {
+			return nil, nil, err
+		}
+		released = append(released, slots...)
+	}
+	return released, swaps, nil
+}
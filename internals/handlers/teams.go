@@ -0,0 +1,554 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/config"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/utils"
+)
+
+const defaultInvitationTTL = 7 * 24 * time.Hour
+
+// teamIDsForUser returns the IDs of every team the user belongs to.
+func teamIDsForUser(uid uint) ([]uint, error) {
+	var ids []uint
+	err := database.DB.Model(&models.TeamMembership{}).Where("user_id = ?", uid).Pluck("team_id", &ids).Error
+	return ids, err
+}
+
+func isTeamMember(db *gorm.DB, uid, teamID uint) (bool, error) {
+	var n int64
+	err := db.Model(&models.TeamMembership{}).Where("user_id = ? AND team_id = ?", uid, teamID).Count(&n).Error
+	return n > 0, err
+}
+
+var errLastAdmin = errors.New("the team's only admin can't leave; make someone else an admin first")
+
+// removeMember takes the user off the team. Their pending swaps and shift
+// claims involving the team's slots are cancelled, they leave the team's
+// rotations and their remaining future team events go back into the
+// open-shift pool. It returns the events that
+// changed and the swaps it cancelled, gorm.ErrRecordNotFound if the user was not a member, or
+// errLastAdmin if they are the team's only admin and don't own its
+// organization.
+func removeMember(tx *gorm.DB, teamID, userID, actorID uint) ([]models.Event, []models.SwapRequest, error) {
+	// locking the admins makes two of them leaving at once wait for each other
+	var admins []models.TeamMembership
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("team_id = ? AND role = ?", teamID, models.RoleAdmin).Order("id").Find(&admins).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(admins) == 1 && admins[0].UserID == userID {
+		var owner int64
+		if err := tx.Model(&models.Organization{}).
+			Where("owner_id = ? AND id = (?)", userID, tx.Model(&models.Team{}).Select("organization_id").Where("id = ?", teamID)).
+			Count(&owner).Error; err != nil {
+			return nil, nil, err
+		}
+		if owner == 0 {
+			return nil, nil, errLastAdmin
+		}
+	}
+
+	res := tx.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&models.TeamMembership{})
+	if res.Error != nil {
+		return nil, nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+
+	teamSlots := tx.Unscoped().Model(&models.Event{}).Select("id").Where("team_id = ?", teamID)
+	changed, cancelled, err := cancelPendingSwaps(tx, actorID,
+		"(requester_id = ? OR receiver_id = ?) AND (my_slot_id IN (?) OR their_slot_id IN (?))",
+		userID, userID, teamSlots, teamSlots)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Model(&models.ShiftClaim{}).
+		Where("user_id = ? AND status = ? AND event_id IN (?)", userID, models.ClaimPending, teamSlots).
+		Update("status", models.ClaimWithdrawn).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := dropRotationMember(tx, teamID, userID); err != nil {
+		return nil, nil, err
+	}
+
+	var events []models.Event
+	if err := tx.Where("team_id = ? AND user_id = ? AND start_time > ?", teamID, userID, time.Now()).
+		Find(&events).Error; err != nil {
+		return nil, nil, err
+	}
+	for i := range events {
+		if err := openShift(tx, &events[i], &actorID); err != nil {
+			return nil, nil, err
+		}
+	}
+	return append(changed, events...), cancelled, nil
+}
+
+// requireTeamPermission writes the error response and returns false unless
+// the user holds perm in the team. Non-members get a 404 so team IDs can't be
+// probed.
+func requireTeamPermission(w http.ResponseWriter, uid, teamID uint, perm access.Permission) bool {
+	role, err := access.RoleFor(uid, teamID)
+	if errors.Is(err, access.ErrNotMember) {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return false
+	}
+	if !access.RoleAllows(role, perm) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// sameTeam reports whether two events live in the same marketplace. Events
+// without a team share the legacy global marketplace.
+func sameTeam(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// Organizations handles GET and POST /api/orgs
+func Organizations(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var orgs []models.Organization
+		if err := database.DB.
+			Where("owner_id = ? OR id IN (?)", uid,
+				database.DB.Model(&models.Team{}).Select("organization_id").
+					Where("id IN (?)", database.DB.Model(&models.TeamMembership{}).Select("team_id").Where("user_id = ?", uid))).
+			Find(&orgs).Error; err != nil {
+			http.Error(w, "Error fetching organizations", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(orgs)
+
+	case http.MethodPost:
+		var input struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		input.Name = strings.TrimSpace(input.Name)
+		if input.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		org := models.Organization{Name: input.Name, OwnerID: uid}
+		if err := database.DB.Create(&org).Error; err != nil {
+			http.Error(w, "Failed to create organization", http.StatusInternalServerError)
+			return
+		}
+		logAudit(r, "org.created", "organization", org.ID, nil)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(org)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// OrganizationTeams handles GET and POST /api/orgs/{id}/teams
+func OrganizationTeams(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	orgID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var org models.Organization
+	if err := database.DB.First(&org, orgID).Error; err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// owners see every team, members only the teams they are in
+		q := database.DB.Where("organization_id = ?", org.ID)
+		if org.OwnerID != uid {
+			q = q.Where("id IN (?)", database.DB.Model(&models.TeamMembership{}).Select("team_id").Where("user_id = ?", uid))
+		}
+		var teams []models.Team
+		if err := q.Find(&teams).Error; err != nil {
+			http.Error(w, "Error fetching teams", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(teams)
+
+	case http.MethodPost:
+		if org.OwnerID != uid {
+			http.Error(w, "Only the organization owner can create teams", http.StatusForbidden)
+			return
+		}
+		var input struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		input.Name = strings.TrimSpace(input.Name)
+		if input.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+
+		team := models.Team{OrganizationID: org.ID, Name: input.Name}
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&team).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.TeamMembership{TeamID: team.ID, UserID: uid, Role: models.RoleAdmin}).Error
+		})
+		if err != nil {
+			http.Error(w, "Failed to create team", http.StatusInternalServerError)
+			return
+		}
+		logAudit(r, "team.created", "team", team.ID, models.AuditDetails{"organizationId": org.ID})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(team)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// OrganizationOwner handles POST /api/orgs/{id}/owner
+// The owner hands the organization to a member of one of its teams.
+func OrganizationOwner(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	orgID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var org models.Organization
+	if err := database.DB.First(&org, orgID).Error; err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+	if org.OwnerID != uid {
+		http.Error(w, "Only the organization owner can transfer it", http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		UserID uint `json:"userId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var n int64
+	if err := database.DB.Model(&models.TeamMembership{}).
+		Where("user_id = ? AND team_id IN (?)", input.UserID,
+			database.DB.Model(&models.Team{}).Select("id").Where("organization_id = ?", org.ID)).
+		Count(&n).Error; err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 || input.UserID == uid {
+		http.Error(w, "userId must be another member of the organization", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.DB.Model(&org).Update("owner_id", input.UserID).Error; err != nil {
+		http.Error(w, "Failed to transfer organization", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "org.transferred", "organization", org.ID, models.AuditDetails{"from": uid, "to": input.UserID})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(org)
+}
+
+// MyTeams handles GET /api/teams
+func MyTeams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var teams []models.Team
+	if err := database.DB.
+		Where("id IN (?)", database.DB.Model(&models.TeamMembership{}).Select("team_id").Where("user_id = ?", uid)).
+		Find(&teams).Error; err != nil {
+		http.Error(w, "Error fetching teams", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(teams)
+}
+
+// TeamMembers handles GET /api/teams/{id}/members
+func TeamMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+	if !requireTeamPermission(w, uid, teamID, access.ViewTeam) {
+		return
+	}
+
+	type Member struct {
+		UserID         uint            `json:"userId"`
+		Name           string          `json:"name"`
+		Role           models.TeamRole `json:"role"`
+		Qualifications pq.StringArray  `json:"qualifications"`
+		JoinedAt       time.Time       `json:"joinedAt"`
+	}
+	var members []Member
+	if err := database.DB.Table("team_memberships").
+		Select("team_memberships.user_id, users.name, team_memberships.role, team_memberships.qualifications, team_memberships.created_at AS joined_at").
+		Joins("JOIN users ON users.id = team_memberships.user_id").
+		Where("team_memberships.team_id = ?", teamID).
+		Order("users.name").
+		Scan(&members).Error; err != nil {
+		http.Error(w, "Error fetching members", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// LeaveTeam handles DELETE /api/teams/{id}/members/me
+func LeaveTeam(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	var changed []models.Event
+	var cancelled []models.SwapRequest
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		changed, cancelled, err = removeMember(tx, teamID, uid, uid)
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errLastAdmin) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to leave team", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "team.left", "team", teamID, nil)
+	emitCancelledSwaps(r, cancelled)
+	publishSlots(changed...)
+	dropTeamSubscriptions(uid, teamID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Left team"})
+}
+
+// CreateTeamInvitation handles POST /api/teams/{id}/invitations
+func CreateTeamInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+	if !requireTeamPermission(w, uid, teamID, access.InviteMembers) {
+		return
+	}
+
+	var input struct {
+		ExpiresInHours int `json:"expiresInHours"`
+		MaxUses        int `json:"maxUses"`
+	}
+	// an empty body means "use the defaults"
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if input.ExpiresInHours < 0 || input.MaxUses < 0 {
+		http.Error(w, "expiresInHours and maxUses must not be negative", http.StatusBadRequest)
+		return
+	}
+	ttl := defaultInvitationTTL
+	if input.ExpiresInHours > 0 {
+		ttl = time.Duration(input.ExpiresInHours) * time.Hour
+	}
+
+	token, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		http.Error(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+	invite := models.TeamInvitation{
+		TeamID:      teamID,
+		CreatedByID: uid,
+		TokenHash:   utils.HashToken(token),
+		ExpiresAt:   time.Now().Add(ttl),
+		MaxUses:     input.MaxUses,
+	}
+	if err := database.DB.Create(&invite).Error; err != nil {
+		http.Error(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "team.invitation_created", "team", teamID, models.AuditDetails{"invitationId": invite.ID, "maxUses": invite.MaxUses})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invitation": invite,
+		"token":      token,
+		"link":       fmt.Sprintf("%s/join?token=%s", strings.TrimRight(config.GetAppURL(), "/"), token),
+	})
+}
+
+var errInvitationInvalid = errors.New("invitation is invalid or expired")
+
+// AcceptInvitation handles POST /api/invitations/accept
+func AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || strings.TrimSpace(input.Token) == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var team models.Team
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var invite models.TeamInvitation
+		if err := tx.Where("token_hash = ?", utils.HashToken(strings.TrimSpace(input.Token))).First(&invite).Error; err != nil {
+			return errInvitationInvalid
+		}
+		if invite.RevokedAt != nil || time.Now().After(invite.ExpiresAt) {
+			return errInvitationInvalid
+		}
+
+		var n int64
+		tx.Model(&models.TeamMembership{}).Where("team_id = ? AND user_id = ?", invite.TeamID, uid).Count(&n)
+		if n == 0 {
+			// conditional increment keeps maxUses exact under concurrent accepts
+			q := tx.Model(&models.TeamInvitation{}).Where("id = ?", invite.ID)
+			if invite.MaxUses > 0 {
+				q = q.Where("uses < max_uses")
+			}
+			res := q.Update("uses", gorm.Expr("uses + 1"))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errInvitationInvalid
+			}
+			if err := tx.Create(&models.TeamMembership{TeamID: invite.TeamID, UserID: uid, Role: models.RoleMember}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.First(&team, invite.TeamID).Error
+	})
+	if err == errInvitationInvalid {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to join team", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "team.invitation_accepted", "team", team.ID, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(team)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/access"
+	"github.com/jfernsio/slotswapper/internals/config"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+// TrashedEvent is a deleted event and when it will be purged.
+type TrashedEvent struct {
+	models.Event
+	PurgeAt time.Time `json:"purgeAt"`
+}
+
+// EventTrash handles GET /api/events/trash
+// It lists the caller's deleted events, or with ?teamId those of a team
+// the caller manages events in.
+func EventTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := database.DB.Unscoped().Where("deleted_at IS NOT NULL")
+	if teamParam := r.URL.Query().Get("teamId"); teamParam != "" {
+		teamID, err := strconv.ParseUint(teamParam, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid team ID", http.StatusBadRequest)
+			return
+		}
+		if !requireTeamPermission(w, uid, uint(teamID), access.ManageEvents) {
+			return
+		}
+		query = query.Where("team_id = ?", teamID)
+	} else {
+		query = query.Where("user_id = ?", uid)
+	}
+
+	var events []models.Event
+	if err := query.Order("deleted_at DESC").Find(&events).Error; err != nil {
+		http.Error(w, "Error fetching trash", http.StatusInternalServerError)
+		return
+	}
+
+	retention := config.GetTrashRetention()
+	out := make([]TrashedEvent, 0, len(events))
+	for _, e := range events {
+		out = append(out, TrashedEvent{Event: e, PurgeAt: e.DeletedAt.Time.Add(retention)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// RestoreEvent handles POST /api/events/{id}/restore
+// Swaps cancelled by the deletion stay cancelled; the slot comes back
+// without any pending trades.
+func RestoreEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	eventID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	var event models.Event
+	if err := database.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&event, eventID).Error; err != nil ||
+		!canEditEvent(uid, event) {
+		http.Error(w, "Event not found in trash", http.StatusNotFound)
+		return
+	}
+
+	updates := map[string]interface{}{"deleted_at": nil}
+	if event.Status == models.SlotSwapPending {
+		updates["status"] = models.SlotSwappable
+	}
+	before := event
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&event).Updates(updates).Error; err != nil {
+			return err
+		}
+		event.DeletedAt = gorm.DeletedAt{}
+		if s, ok := updates["status"]; ok {
+			event.Status = s.(models.SlotStatus)
+		}
+		return recordRevision(tx, before, event, revRestored, &uid, nil)
+	})
+	if err != nil {
+		http.Error(w, "Failed to restore event", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "event.restored", "event", event.ID, nil)
+	emitEventChanged(r, event, "restored")
+	publishSlots(event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
@@ -3,16 +3,10 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
-	"regexp"
-	"strings"
-	"time"
 
-	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
-
-	"github.com/jfernsio/slotswapper/internals/database"
-	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/auth"
 )
 
 // SignupRequest is the JSON shape expected for signup.
@@ -23,34 +17,10 @@ type SignupRequest struct {
 }
 
 type SignupResponse struct {
-	ID    uint   `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-}
-
-// simpleEmailRegexp is intentionally forgiving — it's fine for demo.
-var simpleEmailRegexp = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
-
-// validateSignup validates the input and returns an error message if invalid.
-func validateSignup(req SignupRequest) error {
-	
-	req.Name = strings.TrimSpace(req.Name)
-	req.Email = strings.TrimSpace(req.Email)
-	req.Password = strings.TrimSpace(req.Password)
-
-	if req.Name == "" {
-		return errors.New("name is required")
-	}
-	if req.Email == "" {
-		return errors.New("email is required")
-	}
-	if !simpleEmailRegexp.MatchString(req.Email) {
-		return errors.New("invalid email")
-	}
-	if len(req.Password) < 6 {
-		return errors.New("password must be at least 6 characters")
-	}
-	return nil
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"emailVerified"`
 }
 
 // SignupHandler handles POST /signup
@@ -66,46 +36,36 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := validateSignup(payload); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	user, err := auth.Default.Signup(r.Context(), auth.SignupInput{
+		Name:     payload.Name,
+		Email:    payload.Email,
+		Password: payload.Password,
+	})
+	var verr *auth.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		http.Error(w, verr.Error(), http.StatusBadRequest)
 		return
-	}
-
-	// check if email already exists
-	var existing models.User
-	if err := database.DB.Where("email = ?", payload.Email).First(&existing).Error; err == nil {
+	case errors.Is(err, auth.ErrEmailTaken):
 		http.Error(w, "email already registered", http.StatusConflict)
 		return
-	} else if err != nil && err != gorm.ErrRecordNotFound {
-		http.Error(w, "db error", http.StatusInternalServerError)
-		return
-	}
-
-	// bcrypt hash
-	hash, err := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
-	if err != nil {
-		http.Error(w, "server error hashing password", http.StatusInternalServerError)
+	case err != nil:
+		http.Error(w, "failed to create user", http.StatusInternalServerError)
 		return
 	}
 
-	user := models.User{
-		Name:         payload.Name,
-		Email:        payload.Email,
-		Password: string(hash),
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
-	}
-
-	if err := database.DB.Create(&user).Error; err != nil {
-		http.Error(w, "failed to create user", http.StatusInternalServerError)
-		return
+	// the account exists even if the mail fails; the user can resend later
+	if err := sendEmailVerification(r.Context(), *user, user.Email); err != nil {
+		log.Printf("failed to send verification email to user %d: %v", user.ID, err)
 	}
 
 	resp := SignupResponse{
-		ID:    user.ID,
-		Name:  user.Name,
-		Email: user.Email,
+		ID:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
 	}
+	logAuditAs(r, &user.ID, "auth.signup", "user", user.ID, nil)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
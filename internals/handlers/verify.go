@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jfernsio/slotswapper/internals/config"
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/mailer"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/utils"
+	"gorm.io/gorm"
+)
+
+// resendCooldown stops users from spamming the verification mail.
+const resendCooldown = time.Minute
+
+// sendEmailVerification replaces any outstanding tokens for the user with a
+// fresh one and mails the verification link to email, which is either the
+// account address or a pending new address.
+func sendEmailVerification(ctx context.Context, user models.User, email string) error {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	if err := database.DB.Where("user_id = ? AND used_at IS NULL", user.ID).
+		Delete(&models.EmailVerification{}).Error; err != nil {
+		return err
+	}
+
+	verification := models.EmailVerification{
+		UserID:    user.ID,
+		Email:     email,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(config.GetEmailVerificationTTL()),
+	}
+	if err := database.DB.Create(&verification).Error; err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/verify-email?token=%s", strings.TrimRight(config.GetAppURL(), "/"), url.QueryEscape(token))
+	// sent straight away rather than queued, since the user is waiting on it
+	msg, err := mailer.Render(user.Locale, userLocation(user), "verify_email", map[string]interface{}{
+		"Name":      user.Name,
+		"Link":      link,
+		"ExpiresAt": verification.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	msg.To = email
+	return mailer.Send(ctx, msg)
+}
+
+// VerifyEmail handles GET /api/verify-email?token=<token>
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimSpace(r.URL.Query().Get("token"))
+	if token == "" {
+		http.Error(w, "Missing token", http.StatusBadRequest)
+		return
+	}
+
+	var verification models.EmailVerification
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(token)).First(&verification).Error; err != nil {
+		http.Error(w, "Invalid or already used token", http.StatusBadRequest)
+		return
+	}
+	if verification.UsedAt != nil {
+		http.Error(w, "Invalid or already used token", http.StatusBadRequest)
+		return
+	}
+	if time.Now().After(verification.ExpiresAt) {
+		http.Error(w, "Token expired, request a new one", http.StatusGone)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, verification.UserID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"email_verified": true, "email_verified_at": now}
+	// a token for an address other than the current one confirms an email change
+	if verification.Email != "" && verification.Email != user.Email {
+		if verification.Email != user.PendingEmail {
+			http.Error(w, "Invalid or already used token", http.StatusBadRequest)
+			return
+		}
+		var taken int64
+		database.DB.Model(&models.User{}).Where("LOWER(email) = ? AND id <> ?", verification.Email, user.ID).Count(&taken)
+		if taken > 0 {
+			http.Error(w, "email already registered", http.StatusConflict)
+			return
+		}
+		updates["email"] = verification.Email
+		updates["pending_email"] = ""
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		verification.UsedAt = &now
+		if err := tx.Save(&verification).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user).Updates(updates).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+	_, changed := updates["email"]
+	logAuditAs(r, &user.ID, "auth.email_verified", "user", user.ID, models.AuditDetails{"emailChanged": changed})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email verified"})
+}
+
+// ResendVerification handles POST /api/verify-email/resend
+func ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, uid).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	target := user.Email
+	if user.PendingEmail != "" {
+		target = user.PendingEmail
+	} else if user.EmailVerified {
+		http.Error(w, "Email already verified", http.StatusConflict)
+		return
+	}
+
+	var last models.EmailVerification
+	if err := database.DB.Where("user_id = ?", user.ID).Order("created_at desc").First(&last).Error; err == nil {
+		if time.Since(last.CreatedAt) < resendCooldown {
+			http.Error(w, "Please wait before requesting another email", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	if err := sendEmailVerification(r.Context(), user, target); err != nil {
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "auth.verification_sent", "user", uid, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Verification email sent"})
+}
@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/middleware"
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/webhook"
+)
+
+const (
+	maxWebhooksPerOrg = 10
+	maxDeliveryPage   = 200
+)
+
+// webhookWithSecret is how a webhook is returned when its secret is new;
+// it is never shown again.
+type webhookWithSecret struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+// checkWebhookURL returns a message describing what is wrong with raw, or
+// "" if it can be used as a webhook target.
+func checkWebhookURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "url must be an absolute http or https URL"
+	}
+	if u.User != nil {
+		return "url must not contain credentials"
+	}
+	if len(raw) > 500 {
+		return "url must be at most 500 characters"
+	}
+	return ""
+}
+
+// checkWebhookEvents returns a message describing what is wrong with
+// events, or "" if a webhook may subscribe to all of them.
+func checkWebhookEvents(events []string) string {
+	if len(events) == 0 {
+		return "events is required; use [\"*\"] for every event"
+	}
+	for _, e := range events {
+		if !webhook.ValidEventType(e) {
+			return "unknown event type " + strconv.Quote(e)
+		}
+	}
+	return ""
+}
+
+// ownedWebhook loads a webhook of an organization the user owns, writing a
+// 404 or 403 and returning false otherwise.
+func ownedWebhook(w http.ResponseWriter, uid, id uint) (models.Webhook, bool) {
+	var hook models.Webhook
+	if err := database.DB.First(&hook, id).Error; err != nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return hook, false
+	}
+	var org models.Organization
+	if err := database.DB.First(&org, hook.OrganizationID).Error; err != nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return hook, false
+	}
+	if org.OwnerID != uid {
+		http.Error(w, "Only the organization owner can manage webhooks", http.StatusForbidden)
+		return hook, false
+	}
+	return hook, true
+}
+
+// OrganizationWebhooks handles GET and POST /api/orgs/{id}/webhooks
+// POST registers a URL for the given event types and returns the signing
+// secret, which is only ever shown in this response.
+func OrganizationWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	orgID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var org models.Organization
+	if err := database.DB.First(&org, orgID).Error; err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+	if org.OwnerID != uid {
+		http.Error(w, "Only the organization owner can manage webhooks", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		hooks := []models.Webhook{}
+		if err := database.DB.Where("organization_id = ?", org.ID).Order("id").Find(&hooks).Error; err != nil {
+			http.Error(w, "Error fetching webhooks", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hooks)
+		return
+	}
+
+	var input struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	input.URL = strings.TrimSpace(input.URL)
+	if msg := checkWebhookURL(input.URL); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if msg := checkWebhookEvents(input.Events); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.Webhook{}).Where("organization_id = ?", org.ID).Count(&count).Error; err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if count >= maxWebhooksPerOrg {
+		http.Error(w, "Too many webhooks", http.StatusConflict)
+		return
+	}
+
+	hook := models.Webhook{
+		OrganizationID: org.ID,
+		URL:            input.URL,
+		Secret:         webhook.NewSecret(),
+		Events:         pq.StringArray(input.Events),
+		Active:         true,
+	}
+	if err := database.DB.Create(&hook).Error; err != nil {
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "webhook.created", "webhook", hook.ID, models.AuditDetails{
+		"organizationId": org.ID,
+		"url":            hook.URL,
+		"events":         input.Events,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhookWithSecret{Webhook: hook, Secret: hook.Secret})
+}
+
+// WebhookDetail handles GET, PATCH and DELETE /api/webhooks/{id}
+// PATCH can change url, events and active; rotateSecret issues a new
+// signing secret and returns it. DELETE also drops the delivery log.
+func WebhookDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPatch && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+	hook, ok := ownedWebhook(w, uid, id)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hook)
+
+	case http.MethodPatch:
+		var input struct {
+			URL          *string   `json:"url"`
+			Events       *[]string `json:"events"`
+			Active       *bool     `json:"active"`
+			RotateSecret bool      `json:"rotateSecret"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		updates := map[string]interface{}{}
+		details := models.AuditDetails{}
+		if input.URL != nil {
+			u := strings.TrimSpace(*input.URL)
+			if msg := checkWebhookURL(u); msg != "" {
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+			updates["url"] = u
+			details["url"] = u
+		}
+		if input.Events != nil {
+			if msg := checkWebhookEvents(*input.Events); msg != "" {
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+			updates["events"] = pq.StringArray(*input.Events)
+			details["events"] = *input.Events
+		}
+		if input.Active != nil {
+			updates["active"] = *input.Active
+			details["active"] = *input.Active
+		}
+		if input.RotateSecret {
+			updates["secret"] = webhook.NewSecret()
+			details["secretRotated"] = true
+		}
+		if len(updates) == 0 {
+			http.Error(w, "nothing to update", http.StatusBadRequest)
+			return
+		}
+		if err := database.DB.Model(&hook).Updates(updates).Error; err != nil {
+			http.Error(w, "Failed to update webhook", http.StatusInternalServerError)
+			return
+		}
+		if err := database.DB.First(&hook, hook.ID).Error; err != nil {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		logAudit(r, "webhook.updated", "webhook", hook.ID, details)
+
+		w.Header().Set("Content-Type", "application/json")
+		if input.RotateSecret {
+			json.NewEncoder(w).Encode(webhookWithSecret{Webhook: hook, Secret: hook.Secret})
+			return
+		}
+		json.NewEncoder(w).Encode(hook)
+
+	case http.MethodDelete:
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&hook).Error
+		})
+		if err != nil {
+			http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+			return
+		}
+		logAudit(r, "webhook.deleted", "webhook", hook.ID, models.AuditDetails{
+			"organizationId": hook.OrganizationID,
+			"url":            hook.URL,
+		})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// WebhookDeliveries handles GET /api/webhooks/{id}/deliveries
+// It lists deliveries newest first, up to limit (default 50, max 200).
+// status filters by PENDING, SUCCEEDED or FAILED; pass the last ID seen as
+// before to page back.
+func WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+	hook, ok := ownedWebhook(w, uid, id)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 || limit > maxDeliveryPage {
+		limit = 50
+	}
+	query := database.DB.Where("webhook_id = ?", hook.ID)
+	if status := models.DeliveryStatus(strings.ToUpper(q.Get("status"))); status != "" {
+		if status != models.DeliveryPending && status != models.DeliverySucceeded && status != models.DeliveryFailed {
+			http.Error(w, "status must be PENDING, SUCCEEDED or FAILED", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+	if before := q.Get("before"); before != "" {
+		beforeID, err := strconv.ParseUint(before, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid before", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("id < ?", beforeID)
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	if err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		http.Error(w, "Error fetching deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// RedeliverWebhook handles POST /api/webhooks/{id}/deliveries/{deliveryId}/redeliver
+// It queues a new delivery with the same payload, to be sent right away.
+func RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+	deliveryID, err := pathID(r, "deliveryId")
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+	hook, ok := ownedWebhook(w, uid, id)
+	if !ok {
+		return
+	}
+	if !hook.Active {
+		http.Error(w, "Webhook is disabled", http.StatusConflict)
+		return
+	}
+
+	var original models.WebhookDelivery
+	if err := database.DB.Where("id = ? AND webhook_id = ?", deliveryID, hook.ID).First(&original).Error; err != nil {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	again, err := webhook.Redeliver(database.DB, original)
+	if err != nil {
+		http.Error(w, "Failed to queue redelivery", http.StatusInternalServerError)
+		return
+	}
+	logAudit(r, "webhook.redelivered", "webhook", hook.ID, models.AuditDetails{
+		"deliveryId":   again.ID,
+		"redeliveryOf": original.ID,
+		"eventType":    original.EventType,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(again)
+}
@@ -0,0 +1,188 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/bus"
+	"github.com/jfernsio/slotswapper/internals/config"
+	"github.com/jfernsio/slotswapper/internals/mailer"
+	"github.com/jfernsio/slotswapper/internals/market"
+	"github.com/jfernsio/slotswapper/internals/models"
+	"github.com/jfernsio/slotswapper/internals/webhook"
+)
+
+// webhookBatch is how many due webhook deliveries one run sends.
+const webhookBatch = 50
+
+// Start launches the background jobs. They run for the life of the process.
+func Start(db *gorm.DB) {
+	go every(config.GetTrashPurgeInterval(), func() {
+		n, err := PurgeTrashedEvents(db, time.Now().Add(-config.GetTrashRetention()))
+		if err != nil {
+			log.Printf("trash purge failed: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("purged %d trashed events", n)
+		}
+	})
+	go every(config.GetSwapExpiryInterval(), func() {
+		expired, err := ExpireStaleSwaps(db, time.Now())
+		if err != nil {
+			log.Printf("swap expiry failed: %v", err)
+			return
+		}
+		for _, swap := range expired {
+			data := models.JSONMap{
+				"swapId":      swap.ID,
+				"status":      swap.Status,
+				"mySlotId":    swap.MySlotID,
+				"theirSlotId": swap.TheirSlotID,
+			}
+			if err := bus.Default.Emit(db, bus.SwapExpired, data, swap.RequesterID, swap.ReceiverID); err != nil {
+				log.Printf("failed to emit %s: %v", bus.SwapExpired, err)
+			}
+			var mySlot, theirSlot models.Event
+			if err := db.First(&mySlot, swap.MySlotID).Error; err != nil {
+				log.Printf("failed to load expired swap slots: %v", err)
+				continue
+			}
+			if err := db.First(&theirSlot, swap.TheirSlotID).Error; err != nil {
+				log.Printf("failed to load expired swap slots: %v", err)
+				continue
+			}
+			for _, slot := range []models.Event{mySlot, theirSlot} {
+				if slot.TeamID != nil {
+					market.Default.Publish(market.Delta(slot))
+				}
+			}
+			mailSwapExpired(db, swap, mySlot, theirSlot)
+			if err := webhook.Dispatch(db, mySlot.TeamID, bus.SwapExpired, models.JSONMap{
+				"swap":      swap,
+				"mySlot":    mySlot,
+				"theirSlot": theirSlot,
+			}); err != nil {
+				log.Printf("failed to dispatch %s webhooks: %v", bus.SwapExpired, err)
+			}
+		}
+	})
+	go every(config.GetMailQueueInterval(), func() {
+		if _, err := mailer.ProcessQueue(context.Background(), db, time.Now(), mailBatch, config.GetMailMaxAttempts()); err != nil {
+			log.Printf("mail queue failed: %v", err)
+		}
+	})
+	client := webhook.NewClient(config.GetWebhookTimeout(), config.WebhookAllowPrivate())
+	go every(config.GetWebhookInterval(), func() {
+		if _, err := webhook.ProcessDeliveries(context.Background(), db, client, time.Now(), webhookBatch, config.GetWebhookMaxAttempts()); err != nil {
+			log.Printf("webhook deliveries failed: %v", err)
+		}
+	})
+	go every(config.GetDigestInterval(), func() {
+		n, err := SendDigests(db, time.Now())
+		if err != nil {
+			log.Printf("digest failed: %v", err)
+		}
+		if n > 0 {
+			log.Printf("queued %d digests", n)
+		}
+	})
+}
+
+// every runs fn now and then once per interval.
+func every(interval time.Duration, fn func()) {
+	fn()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		fn()
+	}
+}
+
+// PurgeTrashedEvents permanently removes events deleted before cutoff,
+// together with their shift claims and revisions. Swap requests that never
+// went through are removed too. Decided swaps are kept while the other slot
+// still exists, since they are that slot's trade history; they go once both
+// of their slots are purged.
+func PurgeTrashedEvents(db *gorm.DB, cutoff time.Time) (int64, error) {
+	var purged int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		if err := tx.Unscoped().Model(&models.Event{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		live := tx.Unscoped().Model(&models.Event{}).Select("id").Where("id NOT IN ?", ids)
+		if err := tx.Where("my_slot_id IN ? OR their_slot_id IN ?", ids, ids).
+			Where("status IN ? OR (my_slot_id NOT IN (?) AND their_slot_id NOT IN (?))",
+				[]models.SwapStatus{models.SwapPending, models.SwapAwaitingApproval}, live, live).
+			Delete(&models.SwapRequest{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id IN ?", ids).Delete(&models.ShiftClaim{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id IN ?", ids).Delete(&models.EventRevision{}).Error; err != nil {
+			return err
+		}
+		res := tx.Unscoped().Where("id IN ?", ids).Delete(&models.Event{})
+		purged = res.RowsAffected
+		return res.Error
+	})
+	return purged, err
+}
+
+// ExpireStaleSwaps expires open swaps where either slot has already started
+// and puts the slots back on the market. It returns the expired swaps.
+func ExpireStaleSwaps(db *gorm.DB, now time.Time) ([]models.SwapRequest, error) {
+	var swaps []models.SwapRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		started := tx.Model(&models.Event{}).Select("id").Where("start_time <= ?", now)
+		if err := tx.Where("status IN ?", []models.SwapStatus{models.SwapPending, models.SwapAwaitingApproval}).
+			Where("my_slot_id IN (?) OR their_slot_id IN (?)", started, started).
+			Find(&swaps).Error; err != nil {
+			return err
+		}
+		old, _ := json.Marshal(models.SlotSwapPending)
+		cur, _ := json.Marshal(models.SlotSwappable)
+		for i := range swaps {
+			swaps[i].Status = models.SwapExpired
+			swaps[i].DecidedAt = &now
+			if err := tx.Model(&swaps[i]).Updates(map[string]interface{}{"status": models.SwapExpired, "decided_at": now}).Error; err != nil {
+				return err
+			}
+			var ids []uint
+			if err := tx.Model(&models.Event{}).
+				Where("id IN ? AND status = ?", []uint{swaps[i].MySlotID, swaps[i].TheirSlotID}, models.SlotSwapPending).
+				Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				continue
+			}
+			if err := tx.Model(&models.Event{}).Where("id IN ?", ids).Update("status", models.SlotSwappable).Error; err != nil {
+				return err
+			}
+			for _, id := range ids {
+				if err := tx.Create(&models.EventRevision{
+					EventID: id,
+					SwapID:  &swaps[i].ID,
+					Action:  "swap_expired",
+					Changes: models.RevisionChanges{"status": {Old: old, New: cur}},
+				}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return swaps, err
+}
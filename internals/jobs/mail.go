@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/mailer"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+const (
+	// mailBatch is how many queued emails one run of the sender handles.
+	mailBatch = 100
+	// digestSlots caps how many slots one digest lists.
+	digestSlots = 50
+)
+
+// mailSwapExpired emails both sides of a swap that expired, if they want
+// swap emails.
+func mailSwapExpired(db *gorm.DB, swap models.SwapRequest, mySlot, theirSlot models.Event) {
+	data := map[string]interface{}{"Outcome": "expired", "MySlot": mySlot, "TheirSlot": theirSlot, "Reason": ""}
+	for _, id := range []uint{swap.RequesterID, swap.ReceiverID} {
+		var user models.User
+		if err := db.First(&user, id).Error; err != nil {
+			log.Printf("failed to load user %d for swap_outcome email: %v", id, err)
+			continue
+		}
+		if user.AnonymizedAt != nil || !user.Notifications.SwapEmails {
+			continue
+		}
+		if err := mailer.Queue(db, user, "swap_outcome", data); err != nil {
+			log.Printf("failed to queue swap_outcome email for user %d: %v", id, err)
+		}
+	}
+}
+
+// SendDigests queues the daily digest for every user who wants one and whose
+// digest hour has come in their time zone. A digest lists the slots put up
+// for swap since the previous one, in the user's teams or the team-less
+// market, and is skipped when there are none. It returns how many were
+// queued.
+func SendDigests(db *gorm.DB, now time.Time) (int, error) {
+	var users []models.User
+	if err := db.Where("notify_daily_digest = ? AND anonymized_at IS NULL", true).Find(&users).Error; err != nil {
+		return 0, err
+	}
+	queued := 0
+	for _, user := range users {
+		loc, err := time.LoadLocation(user.TimeZone)
+		if err != nil {
+			loc = time.UTC
+		}
+		if now.In(loc).Hour() != user.Notifications.DigestHour {
+			continue
+		}
+		// the hour comes round once a day, but the job runs several times in it
+		since := now.Add(-24 * time.Hour)
+		if last := user.DigestSentAt; last != nil {
+			if now.Sub(*last) < 23*time.Hour {
+				continue
+			}
+			if last.After(since) {
+				since = *last
+			}
+		}
+
+		teams := db.Model(&models.TeamMembership{}).Select("team_id").Where("user_id = ?", user.ID)
+		var slots []models.Event
+		if err := db.Where("status = ? AND user_id <> ? AND start_time > ? AND updated_at > ?",
+			models.SlotSwappable, user.ID, now, since).
+			Where("team_id IN (?) OR team_id IS NULL", teams).
+			Order("start_time").Limit(digestSlots).Find(&slots).Error; err != nil {
+			return queued, err
+		}
+		if len(slots) > 0 {
+			if err := mailer.Queue(db, user, "slot_digest", map[string]interface{}{"Slots": slots}); err != nil {
+				return queued, err
+			}
+			queued++
+		}
+		if err := db.Model(&models.User{}).Where("id = ?", user.ID).
+			UpdateColumn("digest_sent_at", now).Error; err != nil {
+			return queued, err
+		}
+	}
+	return queued, nil
+}
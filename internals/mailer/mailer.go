@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/jfernsio/slotswapper/internals/config"
+)
+
+// Message is a single outgoing email.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages. Drivers are picked with MAIL_DRIVER.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var Default Mailer = LogMailer{}
+
+func Init() {
+	switch strings.ToLower(config.GetMailDriver()) {
+	case "log", "":
+		Default = LogMailer{}
+	case "smtp":
+		host, port, username, password := config.GetSMTPConfig()
+		if host == "" {
+			log.Printf("⚠️ MAIL_DRIVER=smtp without SMTP_HOST, falling back to log")
+			Default = LogMailer{}
+			return
+		}
+		Default = SMTPMailer{Host: host, Port: port, Username: username, Password: password, From: config.GetMailFrom()}
+	case "file":
+		Default = FileMailer{Dir: config.GetMailDir(), From: config.GetMailFrom()}
+	default:
+		log.Printf("⚠️ unknown MAIL_DRIVER %q, falling back to log", config.GetMailDriver())
+		Default = LogMailer{}
+	}
+}
+
+// Send delivers msg through the configured driver.
+func Send(ctx context.Context, msg Message) error {
+	return Default.Send(ctx, msg)
+}
+
+// LogMailer writes messages to the server log instead of sending them.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("📧 to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
@@ -0,0 +1,115 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/jfernsio/slotswapper/internals/config"
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+// mailLease is how long a claimed email is hidden from other senders while
+// it is being sent.
+const mailLease = 5 * time.Minute
+
+// Queue renders the named template in the user's locale and time zone and
+// stores it for the sender job. Name and AppURL are added to data.
+func Queue(db *gorm.DB, user models.User, name string, data map[string]interface{}) error {
+	loc, err := time.LoadLocation(user.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	vars := map[string]interface{}{"Name": user.Name, "AppURL": config.GetAppURL()}
+	for k, v := range data {
+		vars[k] = v
+	}
+	msg, err := Render(user.Locale, loc, name, vars)
+	if err != nil {
+		return err
+	}
+	return db.Create(&models.QueuedMail{
+		UserID:        &user.ID,
+		Template:      name,
+		To:            user.Email,
+		Subject:       msg.Subject,
+		Text:          msg.Text,
+		HTML:          msg.HTML,
+		Status:        models.MailPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// retryDelay doubles from a minute after each failed attempt, up to an hour.
+func retryDelay(attempts int) time.Duration {
+	d := time.Minute
+	for i := 1; i < attempts && d < time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, time.Hour)
+}
+
+// ProcessQueue sends up to batch due emails through Default and returns how
+// many went out. Each email is leased just before it is sent so several
+// processes can drain the queue without sending anything twice, however long
+// the batch takes.
+func ProcessQueue(ctx context.Context, db *gorm.DB, now time.Time, batch, maxAttempts int) (int, error) {
+	sent := 0
+	for range batch {
+		m, err := leaseMail(db, now)
+		if err != nil || m == nil {
+			return sent, err
+		}
+		err = Send(ctx, Message{To: m.To, Subject: m.Subject, Text: m.Text, HTML: m.HTML})
+		updates := map[string]interface{}{"attempts": m.Attempts + 1}
+		switch {
+		case err == nil:
+			sent++
+			updates["status"] = models.MailSent
+			updates["sent_at"] = time.Now()
+			updates["last_error"] = ""
+		case m.Attempts+1 >= maxAttempts:
+			log.Printf("giving up on mail %d to %s after %d attempts: %v", m.ID, m.To, m.Attempts+1, err)
+			updates["status"] = models.MailFailed
+			updates["last_error"] = truncate(err.Error(), 500)
+		default:
+			updates["next_attempt_at"] = time.Now().Add(retryDelay(m.Attempts + 1))
+			updates["last_error"] = truncate(err.Error(), 500)
+		}
+		if err := db.Model(m).Updates(updates).Error; err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// leaseMail claims the next due email for mailLease, or returns nil when
+// nothing is due.
+func leaseMail(db *gorm.DB, now time.Time) (*models.QueuedMail, error) {
+	var due []models.QueuedMail
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.MailPending, now).
+			Order("next_attempt_at").Limit(1).Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		return tx.Model(&due[0]).Update("next_attempt_at", time.Now().Add(mailLease)).Error
+	})
+	if err != nil || len(due) == 0 {
+		return nil, err
+	}
+	return &due[0], nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
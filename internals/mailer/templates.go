@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"io/fs"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// DefaultLocale is used for users whose locale has no translation of a
+// template.
+const DefaultLocale = "en"
+
+//go:embed templates
+var templateFS embed.FS
+
+// Locales lists the locales that have templates.
+func Locales() []string {
+	var locales []string
+	entries, _ := fs.ReadDir(templateFS, "templates")
+	for _, e := range entries {
+		if e.IsDir() {
+			locales = append(locales, e.Name())
+		}
+	}
+	return locales
+}
+
+// HasLocale reports whether emails can be written in locale.
+func HasLocale(locale string) bool {
+	return slices.Contains(Locales(), locale)
+}
+
+// Render builds the named email in locale. Each template file defines
+// "subject", "text" and "html" blocks and may use the "footer_text" and
+// "footer_html" blocks from the locale's base.tmpl. The "when" function
+// formats a time in loc.
+func Render(locale string, loc *time.Location, name string, data map[string]interface{}) (Message, error) {
+	if _, err := fs.Stat(templateFS, "templates/"+locale+"/"+name+".tmpl"); err != nil {
+		locale = DefaultLocale
+	}
+	files := []string{"templates/" + locale + "/base.tmpl", "templates/" + locale + "/" + name + ".tmpl"}
+	funcs := map[string]interface{}{
+		"when": func(t time.Time) string {
+			return t.In(loc).Format("2006-01-02 15:04 MST")
+		},
+	}
+
+	tt, err := texttemplate.New(name).Funcs(funcs).ParseFS(templateFS, files...)
+	if err != nil {
+		return Message{}, err
+	}
+	ht, err := htmltemplate.New(name).Funcs(funcs).ParseFS(templateFS, files...)
+	if err != nil {
+		return Message{}, err
+	}
+	var subject, text, html bytes.Buffer
+	if err := tt.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := tt.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, err
+	}
+	if err := ht.ExecuteTemplate(&html, "html", data); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    strings.TrimSpace(html.String()),
+	}, nil
+}
@@ -0,0 +1,224 @@
+// Package market fans slot status changes out to clients watching a team's
+// swap marketplace.
+package market
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+// clientBuffer is how many messages a client may fall behind before it is
+// dropped.
+const clientBuffer = 64
+
+var (
+	ErrTooManyConnections = errors.New("too many marketplace connections")
+	// ErrSlowClient and ErrRevoked say why a client was dropped.
+	ErrSlowClient = errors.New("client too slow")
+	ErrRevoked    = errors.New("access revoked")
+)
+
+// SlotDelta is the new state of one slot on a team's board. Removed is set
+// when the slot was deleted.
+type SlotDelta struct {
+	Type      string            `json:"type"`
+	TeamID    uint              `json:"teamId"`
+	EventID   uint              `json:"eventId"`
+	Status    models.SlotStatus `json:"status"`
+	OwnerID   *uint             `json:"ownerId"`
+	Title     string            `json:"title"`
+	StartTime time.Time         `json:"startTime"`
+	EndTime   time.Time         `json:"endTime"`
+	Removed   bool              `json:"removed"`
+}
+
+// Delta describes the event's current state. The event must belong to a
+// team.
+func Delta(e models.Event) SlotDelta {
+	return SlotDelta{
+		Type:      "slot",
+		TeamID:    *e.TeamID,
+		EventID:   e.ID,
+		Status:    e.Status,
+		OwnerID:   e.UserID,
+		Title:     e.Title,
+		StartTime: e.StartTime,
+		EndTime:   e.EndTime,
+		Removed:   e.DeletedAt.Valid,
+	}
+}
+
+// Client is one connection. Messages to send arrive on Send; Dropped is
+// closed if the hub gives up on the client, after which Err says why.
+type Client struct {
+	UserID  uint
+	Send    chan []byte
+	Dropped chan struct{}
+	Err     error
+
+	teams   map[uint]bool
+	dropped bool
+}
+
+// Hub tracks which clients watch which teams.
+type Hub struct {
+	mu    sync.Mutex
+	teams map[uint]map[*Client]struct{}
+	users map[uint]map[*Client]struct{}
+}
+
+// Default is the process-wide hub.
+var Default = NewHub()
+
+func NewHub() *Hub {
+	return &Hub{
+		teams: map[uint]map[*Client]struct{}{},
+		users: map[uint]map[*Client]struct{}{},
+	}
+}
+
+// Register adds a connection for the user, refusing it once the user has
+// limit connections open.
+func (h *Hub) Register(userID uint, limit int) (*Client, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.users[userID]) >= limit {
+		return nil, ErrTooManyConnections
+	}
+	c := &Client{
+		UserID:  userID,
+		Send:    make(chan []byte, clientBuffer),
+		Dropped: make(chan struct{}),
+		teams:   map[uint]bool{},
+	}
+	if h.users[userID] == nil {
+		h.users[userID] = map[*Client]struct{}{}
+	}
+	h.users[userID][c] = struct{}{}
+	return c, nil
+}
+
+// Unregister removes the client from every channel.
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leaveAllLocked(c)
+	delete(h.users[c.UserID], c)
+	if len(h.users[c.UserID]) == 0 {
+		delete(h.users, c.UserID)
+	}
+}
+
+// DropUser disconnects every client of the user, for when the account is
+// deleted.
+func (h *Hub) DropUser(userID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.users[userID] {
+		h.dropLocked(c, ErrRevoked)
+	}
+}
+
+func (h *Hub) dropLocked(c *Client, err error) {
+	if c.dropped {
+		return
+	}
+	c.dropped = true
+	c.Err = err
+	h.leaveAllLocked(c)
+	close(c.Dropped)
+}
+
+func (h *Hub) leaveAllLocked(c *Client) {
+	for teamID := range c.teams {
+		h.unsubscribeLocked(c, teamID)
+	}
+}
+
+// Subscribe starts sending the team's deltas to the client.
+func (h *Hub) Subscribe(c *Client, teamID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if c.dropped {
+		return
+	}
+	if h.teams[teamID] == nil {
+		h.teams[teamID] = map[*Client]struct{}{}
+	}
+	h.teams[teamID][c] = struct{}{}
+	c.teams[teamID] = true
+}
+
+// Unsubscribe stops sending the team's deltas to the client.
+func (h *Hub) Unsubscribe(c *Client, teamID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unsubscribeLocked(c, teamID)
+}
+
+func (h *Hub) unsubscribeLocked(c *Client, teamID uint) {
+	delete(c.teams, teamID)
+	delete(h.teams[teamID], c)
+	if len(h.teams[teamID]) == 0 {
+		delete(h.teams, teamID)
+	}
+}
+
+// LeaveTeam stops sending the team's deltas to the user's clients, for
+// when they leave or are removed from it. It returns the clients that were
+// watching the team.
+func (h *Hub) LeaveTeam(userID, teamID uint) []*Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var left []*Client
+	for c := range h.users[userID] {
+		if c.teams[teamID] {
+			h.unsubscribeLocked(c, teamID)
+			left = append(left, c)
+		}
+	}
+	return left
+}
+
+// SendTo queues a message for one client, dropping the client if it is
+// too far behind.
+func (h *Hub) SendTo(c *Client, v interface{}) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sendLocked(c, msg)
+	return nil
+}
+
+func (h *Hub) sendLocked(c *Client, msg []byte) {
+	if c.dropped {
+		return
+	}
+	select {
+	case c.Send <- msg:
+	default:
+		// a slow client would hold up everyone else on the channel, so it
+		// is cut off and has to reload the board when it reconnects
+		h.dropLocked(c, ErrSlowClient)
+	}
+}
+
+// Publish sends the delta to everyone watching its team.
+func (h *Hub) Publish(d SlotDelta) {
+	msg, err := json.Marshal(d)
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.teams[d.TeamID] {
+		h.sendLocked(c, msg)
+	}
+}
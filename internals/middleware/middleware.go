@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/jfernsio/slotswapper/internals/database"
+	"github.com/jfernsio/slotswapper/internals/models"
 	"github.com/jfernsio/slotswapper/internals/utils"
 )
 
@@ -17,17 +19,74 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		}
 		tokenStr := strings.TrimPrefix(auth, "Bearer ")
 		claims, err := utils.VerifyToken(tokenStr)
-		if err != nil {
+		if err != nil || utils.TokenType(claims) != utils.TokenTypeAccess {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), "user_id", (*claims)["user_id"])
+		// a deleted account's tokens stop working straight away rather than
+		// when they expire
+		uid, _ := UserIDFromContext(ctx)
+		var user models.User
+		if err := database.DB.Select("id", "anonymized_at").First(&user, uid).Error; err != nil || user.AnonymizedAt != nil {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// TokenFromQuery lets clients that can't set headers, such as EventSource and
+// browser WebSockets, pass the access token as ?access_token. It must be
+// chained before AuthMiddleware and only used on routes that need it, since
+// URLs end up in logs.
+func TokenFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			if token := r.URL.Query().Get("access_token"); token != "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UserIDFromContext returns the authenticated user's ID set by AuthMiddleware.
+func UserIDFromContext(ctx context.Context) (uint, bool) {
+	switch v := ctx.Value("user_id").(type) {
+	case float64:
+		return uint(v), true
+	case int:
+		return uint(v), true
+	case uint:
+		return v, true
+	default:
+		return 0, false
+	}
+}
 
+// RequireVerifiedEmail blocks users who have not confirmed their email yet.
+// It must be chained after AuthMiddleware.
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := UserIDFromContext(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var user models.User
+		if err := database.DB.Select("id", "email_verified").First(&user, uid).Error; err != nil {
+			http.Error(w, "User not found", http.StatusUnauthorized)
+			return
+		}
+		if !user.EmailVerified {
+			http.Error(w, "email not verified", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
 
 func EnableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
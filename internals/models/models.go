@@ -1,7 +1,17 @@
 
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"github.com/jfernsio/slotswapper/internals/rules"
+)
 
 type SlotStatus string
 type SwapStatus string
@@ -10,10 +20,16 @@ const (
 	SlotBusy       SlotStatus = "BUSY"
 	SlotSwappable  SlotStatus = "SWAPPABLE"
 	SlotSwapPending SlotStatus = "SWAP_PENDING"
+	// SlotOpen marks an unassigned shift in the team's open-shift pool
+	SlotOpen SlotStatus = "OPEN"
 
 	SwapPending  SwapStatus = "PENDING"
 	SwapAccepted SwapStatus = "ACCEPTED"
 	SwapRejected SwapStatus = "REJECTED"
+	SwapCancelled SwapStatus = "CANCELLED"
+	SwapReverted SwapStatus = "REVERTED"
+	SwapAwaitingApproval SwapStatus = "AWAITING_APPROVAL"
+	SwapExpired SwapStatus = "EXPIRED"
 )
 
 type User struct {
@@ -21,19 +37,58 @@ type User struct {
 	Name         string `gorm:"size:200;not null"`
 	Email        string `gorm:"size:200;uniqueIndex;not null"`
 	Password string `gorm:"size:300;not null"`
+	EmailVerified   bool       `gorm:"not null;default:false"`
+	EmailVerifiedAt *time.Time
+	TOTPSecret      string `gorm:"size:64" json:"-"`
+	TOTPEnabled     bool   `gorm:"not null;default:false"`
+	TOTPLastStep    int64  `gorm:"not null;default:0" json:"-"`
+	PendingEmail    string `gorm:"size:200"`
+	TimeZone        string `gorm:"size:64;not null;default:'UTC'"`
+	Locale          string `gorm:"size:10;not null;default:'en'"`
+	AvatarKey       string `gorm:"size:300"`
+	AvatarType      string `gorm:"size:50"`
+	Notifications   NotificationPrefs `gorm:"embedded;embeddedPrefix:notify_"`
+	AnonymizedAt    *time.Time
+	// DigestSentAt is when the last daily digest went out
+	DigestSentAt    *time.Time
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
 
+// NotificationPrefs are the per-user switches for outgoing notifications.
+type NotificationPrefs struct {
+	// SwapEmails covers incoming swap requests and swap outcomes
+	SwapEmails  bool `gorm:"not null;default:true" json:"swapEmails"`
+	// ShiftEmails covers open shifts being handed out
+	ShiftEmails bool `gorm:"not null;default:true" json:"shiftEmails"`
+	DailyDigest bool `gorm:"not null;default:false" json:"dailyDigest"`
+	// DigestHour is the local hour (0-23) the daily digest is sent at
+	DigestHour  int  `gorm:"not null;default:8" json:"digestHour"`
+}
+
 type Event struct {
 	ID        uint       `gorm:"primaryKey" json:"id"`
 	Title     string     `gorm:"size:300;not null"`
 	StartTime time.Time  `gorm:"not null"`
 	EndTime   time.Time  `gorm:"not null"`
 	Status    SlotStatus `gorm:"type:VARCHAR(20);not null;default:'BUSY'"`
-	UserID   uint		 `gorm:"userId"`
+	// UserID is nil while the slot sits in the open-shift pool
+	UserID   *uint		 `gorm:"userId"`
+	TeamID    *uint      `gorm:"index" json:"teamId"`
+	// ClaimMode decides how an open shift is handed out
+	ClaimMode ClaimMode `gorm:"size:20" json:"claimMode,omitempty"`
+	// RotationID links events generated from an on-call rotation
+	RotationID *uint `gorm:"index" json:"rotationId,omitempty"`
+	// RotationAssigneeID is who the rotation gave the slot to; once the
+	// owner differs the slot is left alone when the rotation regenerates
+	RotationAssigneeID *uint `json:"-"`
+	// RequiredQualifications must all be held by whoever owns the slot
+	RequiredQualifications pq.StringArray `gorm:"type:text[]" json:"requiredQualifications"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	// DeletedAt puts the event in the trash; it is purged after the
+	// retention period
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt"`
 }
 
 type SwapRequest struct {
@@ -44,6 +99,431 @@ type SwapRequest struct {
 	RequesterID  uint        `json:"requesterId"`
 	ReceiverID   uint        `json:"receiverId"`
 	Status       SwapStatus  `gorm:"type:VARCHAR(20);not null;default:'PENDING'"`
+	ApproverID   *uint       `json:"approverId"`
+	DecidedAt    *time.Time  `json:"decidedAt"`
+	Reason       string      `gorm:"size:500" json:"reason"`
+	// Warnings carries non-blocking labor rule violations back to the client
+	Warnings     []rules.Violation `gorm:"-" json:"warnings,omitempty"`
 	CreatedAt    time.Time   `json:"createdAt"`
 	UpdatedAt    time.Time   `json:"updatedAt"`
 }
+
+// EmailVerification holds a single-use token mailed to a user to confirm
+// their address. Only the SHA-256 hash of the token is stored.
+type EmailVerification struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"userId"`
+	Email     string    `gorm:"size:200" json:"email"`
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time `gorm:"not null" json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RecoveryCode is a one-time backup code for two-factor login, stored hashed.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"userId"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// LoginAttempt records every login try, used for throttling and auditing.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Email     string    `gorm:"size:200;index;not null" json:"email"`
+	UserID    *uint     `gorm:"index" json:"userId"`
+	IP        string    `gorm:"size:64;index;not null" json:"ip"`
+	UserAgent string    `gorm:"size:300" json:"userAgent"`
+	Success   bool      `gorm:"not null" json:"success"`
+	Reason    string    `gorm:"size:50" json:"reason"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+}
+
+// Organization groups teams, e.g. one company.
+type Organization struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:200;not null" json:"name"`
+	OwnerID   uint      `gorm:"index;not null" json:"ownerId"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Team is the unit swaps are scoped to: slots are only offered to, and
+// swapped with, people who share the slot's team.
+type Team struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"index;not null" json:"organizationId"`
+	Name           string    `gorm:"size:200;not null" json:"name"`
+	// RequireSwapApproval holds accepted swaps until a manager signs off
+	RequireSwapApproval bool      `gorm:"not null;default:false" json:"requireSwapApproval"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// LaborEnforcement decides what happens when a swap breaks a labor rule.
+type LaborEnforcement string
+
+const (
+	// EnforceBlock rejects the swap outright.
+	EnforceBlock LaborEnforcement = "block"
+	// EnforceWarn lets the swap through but sends it to a manager, whose
+	// approval overrides the warnings.
+	EnforceWarn LaborEnforcement = "warn"
+)
+
+// TeamLaborRules configures the labor rules checked on swaps in a team. A
+// zero limit disables that rule. Weeks and consecutive days are counted in
+// UTC, whatever the members' time zones.
+type TeamLaborRules struct {
+	ID                 uint             `gorm:"primaryKey" json:"-"`
+	TeamID             uint             `gorm:"uniqueIndex;not null" json:"teamId"`
+	MaxHoursPerWeek    float64          `gorm:"not null;default:0" json:"maxHoursPerWeek"`
+	MinRestHours       float64          `gorm:"not null;default:0" json:"minRestHours"`
+	MaxConsecutiveDays int              `gorm:"not null;default:0" json:"maxConsecutiveDays"`
+	Enforcement        LaborEnforcement `gorm:"type:VARCHAR(10);not null;default:'block'" json:"enforcement"`
+	UpdatedAt          time.Time        `json:"updatedAt"`
+}
+
+// TeamSwapPolicy limits when and how much members of a team can trade.
+// Zero values disable a limit.
+type TeamSwapPolicy struct {
+	ID              uint      `gorm:"primaryKey" json:"-"`
+	TeamID          uint      `gorm:"uniqueIndex;not null" json:"teamId"`
+	MinLeadHours    float64   `gorm:"not null;default:0" json:"minLeadHours"`
+	MaxOpenRequests int       `gorm:"not null;default:0" json:"maxOpenRequests"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// SwapBlackout is a freeze period: slots overlapping it can't be swapped.
+type SwapBlackout struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	TeamID      uint      `gorm:"index;not null" json:"teamId"`
+	StartTime   time.Time `gorm:"not null" json:"startTime"`
+	EndTime     time.Time `gorm:"not null" json:"endTime"`
+	Reason      string    `gorm:"size:200" json:"reason"`
+	CreatedByID uint      `gorm:"not null" json:"createdById"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type TeamRole string
+
+const (
+	RoleMember  TeamRole = "member"
+	RoleManager TeamRole = "manager"
+	RoleAdmin   TeamRole = "admin"
+)
+
+type TeamMembership struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TeamID    uint      `gorm:"uniqueIndex:idx_team_user;not null" json:"teamId"`
+	UserID    uint      `gorm:"uniqueIndex:idx_team_user;index;not null" json:"userId"`
+	Role      TeamRole  `gorm:"type:VARCHAR(20);not null;default:'member'" json:"role"`
+	// Qualifications are tags such as "pharmacist", granted by managers
+	Qualifications pq.StringArray `gorm:"type:text[]" json:"qualifications"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TeamInvitation is a shareable join link. Only the token hash is stored.
+type TeamInvitation struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	TeamID      uint       `gorm:"index;not null" json:"teamId"`
+	CreatedByID uint       `gorm:"not null" json:"createdById"`
+	TokenHash   string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expiresAt"`
+	MaxUses     int        `gorm:"not null;default:0" json:"maxUses"`
+	Uses        int        `gorm:"not null;default:0" json:"uses"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// ClaimMode decides how an open shift is handed out.
+type ClaimMode string
+
+const (
+	// ClaimFirstCome gives the shift to the first qualified member to claim it.
+	ClaimFirstCome ClaimMode = "first_come"
+	// ClaimManager collects claims and lets a manager pick one.
+	ClaimManager ClaimMode = "manager"
+)
+
+type ClaimStatus string
+
+const (
+	ClaimPending   ClaimStatus = "PENDING"
+	ClaimGranted   ClaimStatus = "GRANTED"
+	ClaimDeclined  ClaimStatus = "DECLINED"
+	ClaimWithdrawn ClaimStatus = "WITHDRAWN"
+)
+
+// ShiftClaim is a member's request for an open shift. A user has at most
+// one claim per shift.
+type ShiftClaim struct {
+	ID        uint        `gorm:"primaryKey" json:"id"`
+	EventID   uint        `gorm:"uniqueIndex:idx_claim_event_user;not null" json:"eventId"`
+	UserID    uint        `gorm:"uniqueIndex:idx_claim_event_user;index;not null" json:"userId"`
+	Status    ClaimStatus `gorm:"type:VARCHAR(20);not null;default:'PENDING'" json:"status"`
+	DecidedAt *time.Time  `json:"decidedAt"`
+	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+}
+
+// Rotation is an on-call schedule that generates team events. Members take
+// shifts in the order given; StartDate and HandoffTime are wall-clock values
+// in TimeZone.
+type Rotation struct {
+	ID          uint                `gorm:"primaryKey" json:"id"`
+	TeamID      uint                `gorm:"index;not null" json:"teamId"`
+	Name        string              `gorm:"size:200;not null" json:"name"`
+	MemberIDs   pq.Int64Array       `gorm:"type:bigint[]" json:"memberIds"`
+	ShiftHours  int                 `gorm:"not null" json:"shiftHours"`
+	HandoffTime string              `gorm:"size:5;not null" json:"handoffTime"`
+	TimeZone    string              `gorm:"size:64;not null;default:'UTC'" json:"timeZone"`
+	StartDate   string              `gorm:"size:10;not null" json:"startDate"`
+	WeeksAhead  int                 `gorm:"not null;default:4" json:"weeksAhead"`
+	CreatedByID uint                `gorm:"not null" json:"createdById"`
+	Exclusions  []RotationExclusion `json:"exclusions,omitempty"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	UpdatedAt   time.Time           `json:"updatedAt"`
+}
+
+// RotationExclusion keeps a member out of a rotation for a period; their
+// shifts go to the next member in line.
+type RotationExclusion struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	RotationID uint      `gorm:"index;not null" json:"rotationId"`
+	UserID     uint      `gorm:"not null" json:"userId"`
+	StartTime  time.Time `gorm:"not null" json:"startTime"`
+	EndTime    time.Time `gorm:"not null" json:"endTime"`
+	Reason     string    `gorm:"size:200" json:"reason"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// OwnedBy reports whether the slot is assigned to the user.
+func (e Event) OwnedBy(userID uint) bool {
+	return e.UserID != nil && *e.UserID == userID
+}
+
+// OwnerID returns the slot's owner, or 0 for an open shift.
+func (e Event) OwnerID() uint {
+	if e.UserID == nil {
+		return 0
+	}
+	return *e.UserID
+}
+
+type AvailabilityKind string
+
+const (
+	AvailabilityLeave       AvailabilityKind = "leave"
+	AvailabilityUnavailable AvailabilityKind = "unavailable"
+)
+
+// AvailabilityBlock is a period a user can't work, such as a vacation. It
+// is not a slot and can't be traded.
+type AvailabilityBlock struct {
+	ID        uint             `gorm:"primaryKey" json:"id"`
+	UserID    uint             `gorm:"index;not null" json:"userId"`
+	Kind      AvailabilityKind `gorm:"type:VARCHAR(20);not null;default:'unavailable'" json:"kind"`
+	StartTime time.Time        `gorm:"not null" json:"startTime"`
+	EndTime   time.Time        `gorm:"not null" json:"endTime"`
+	Note      string           `gorm:"size:200" json:"note"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+// PreferredHours is a window a user likes to work on one weekday, as
+// wall-clock times (HH:MM) in the user's time zone.
+type PreferredHours struct {
+	ID        uint   `gorm:"primaryKey" json:"-"`
+	UserID    uint   `gorm:"index;not null" json:"-"`
+	Weekday   int    `gorm:"not null" json:"weekday"`
+	StartTime string `gorm:"size:5;not null" json:"startTime"`
+	EndTime   string `gorm:"size:5;not null" json:"endTime"`
+}
+
+// FieldChange is the old and new JSON value of one event field.
+type FieldChange struct {
+	Old json.RawMessage `json:"old"`
+	New json.RawMessage `json:"new"`
+}
+
+// RevisionChanges maps event fields to how they changed. It is stored as
+// jsonb.
+type RevisionChanges map[string]FieldChange
+
+func (c RevisionChanges) Value() (driver.Value, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (c *RevisionChanges) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	case nil:
+		*c = nil
+		return nil
+	}
+	return errors.New("unsupported type for RevisionChanges")
+}
+
+// EventRevision records one change to an event: who made it, which swap
+// caused it if any, and the old and new values of the fields that changed.
+type EventRevision struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	EventID   uint            `gorm:"index;not null" json:"eventId"`
+	ActorID   *uint           `json:"actorId"`
+	SwapID    *uint           `gorm:"index" json:"swapId"`
+	Action    string          `gorm:"size:30;not null" json:"action"`
+	Changes   RevisionChanges `gorm:"type:jsonb;not null" json:"changes"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// JSONMap is a free-form JSON object stored as jsonb.
+type JSONMap map[string]interface{}
+
+func (m JSONMap) Value() (driver.Value, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (m *JSONMap) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	case nil:
+		*m = nil
+		return nil
+	}
+	return errors.New("unsupported type for JSONMap")
+}
+
+// AuditDetails is free-form context for an audit entry.
+type AuditDetails = JSONMap
+
+// AuditEntry is one row of the append-only audit log. Each row's Hash
+// covers its own fields and the previous row's hash, so editing or deleting
+// a row breaks the chain from that point on.
+type AuditEntry struct {
+	ID         uint         `gorm:"primaryKey" json:"id"`
+	ActorID    *uint        `gorm:"index" json:"actorId"`
+	Action     string       `gorm:"size:50;index;not null" json:"action"`
+	TargetType string       `gorm:"size:30;index:idx_audit_target" json:"targetType"`
+	TargetID   *uint        `gorm:"index:idx_audit_target" json:"targetId"`
+	IP         string       `gorm:"size:64" json:"ip"`
+	UserAgent  string       `gorm:"size:300" json:"userAgent"`
+	Details    AuditDetails `gorm:"type:jsonb" json:"details"`
+	CreatedAt  time.Time    `gorm:"index;not null" json:"createdAt"`
+	PrevHash   string       `gorm:"size:64;not null" json:"prevHash"`
+	Hash       string       `gorm:"size:64;uniqueIndex;not null" json:"hash"`
+}
+
+// Notification is a domain event addressed to one user. The ID doubles as
+// the SSE event ID, so a client can resume from the last one it saw. It stays
+// in the user's inbox until deleted; ReadAt is nil while unread.
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index:idx_notification_user;not null" json:"-"`
+	Type      string     `gorm:"size:50;not null" json:"type"`
+	Data      JSONMap    `gorm:"type:jsonb" json:"data"`
+	ReadAt    *time.Time `json:"readAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// SavedSearch is a marketplace filter whose owner wants to hear about new
+// matching slots. Empty fields match anything; a nil TeamID covers all of
+// the user's teams. Weekdays and the HH:MM window are in the user's time
+// zone.
+type SavedSearch struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	UserID    uint          `gorm:"index;not null" json:"-"`
+	Name      string        `gorm:"size:100;not null" json:"name"`
+	TeamID    *uint         `gorm:"index" json:"teamId"`
+	Keyword   string        `gorm:"size:100" json:"keyword"`
+	Weekdays  pq.Int64Array `gorm:"type:bigint[]" json:"weekdays"`
+	StartTime string        `gorm:"size:5" json:"startTime"`
+	EndTime   string        `gorm:"size:5" json:"endTime"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+// MailStatus is where a queued email is in its delivery.
+type MailStatus string
+
+const (
+	MailPending MailStatus = "PENDING"
+	MailSent    MailStatus = "SENT"
+	MailFailed  MailStatus = "FAILED"
+)
+
+// QueuedMail is a rendered email waiting for the sender job, or the record
+// of one it handled. Failed attempts are retried with backoff until the
+// attempt limit, after which the mail is marked FAILED.
+type QueuedMail struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        *uint      `gorm:"index" json:"userId"`
+	Template      string     `gorm:"size:50" json:"template"`
+	To            string     `gorm:"size:200;not null" json:"to"`
+	Subject       string     `gorm:"size:300;not null" json:"subject"`
+	Text          string     `gorm:"type:text" json:"-"`
+	HTML          string     `gorm:"type:text" json:"-"`
+	Status        MailStatus `gorm:"type:VARCHAR(20);not null;default:'PENDING';index:idx_mail_due,priority:1" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_mail_due,priority:2" json:"nextAttemptAt"`
+	LastError     string     `gorm:"size:500" json:"lastError,omitempty"`
+	SentAt        *time.Time `json:"sentAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// Webhook posts an organization's swap and event changes to an outside
+// system. Events lists the event types it wants, or "*" for all; Secret
+// signs every payload.
+type Webhook struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	OrganizationID uint           `gorm:"index;not null" json:"organizationId"`
+	URL            string         `gorm:"size:500;not null" json:"url"`
+	Secret         string         `gorm:"size:100;not null" json:"-"`
+	Events         pq.StringArray `gorm:"type:text[]" json:"events"`
+	Active         bool           `gorm:"not null;default:true" json:"active"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+}
+
+// DeliveryStatus is where a webhook delivery is in its attempts.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "PENDING"
+	DeliverySucceeded DeliveryStatus = "SUCCEEDED"
+	DeliveryFailed    DeliveryStatus = "FAILED"
+)
+
+// WebhookDelivery is one payload for a webhook and the outcome of sending
+// it. Failed attempts are retried with backoff until the attempt limit. A
+// manual redelivery is a new row pointing at the original.
+type WebhookDelivery struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	WebhookID      uint           `gorm:"index;not null" json:"webhookId"`
+	EventID        string         `gorm:"size:40;not null" json:"eventId"`
+	EventType      string         `gorm:"size:50;not null" json:"eventType"`
+	Payload        JSONMap        `gorm:"type:jsonb" json:"payload"`
+	Status         DeliveryStatus `gorm:"type:VARCHAR(20);not null;default:'PENDING';index:idx_delivery_due,priority:1" json:"status"`
+	Attempts       int            `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time      `gorm:"not null;index:idx_delivery_due,priority:2" json:"nextAttemptAt"`
+	ResponseStatus int            `json:"responseStatus,omitempty"`
+	ResponseBody   string         `gorm:"size:1000" json:"responseBody,omitempty"`
+	LastError      string         `gorm:"size:500" json:"lastError,omitempty"`
+	DeliveredAt    *time.Time     `json:"deliveredAt"`
+	RedeliveryOf   *uint          `json:"redeliveryOf,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt"`
+}
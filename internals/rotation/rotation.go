@@ -0,0 +1,127 @@
+package rotation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	dateLayout  = "2006-01-02"
+	clockLayout = "15:04"
+)
+
+// Definition describes an on-call rotation: members take turns in order,
+// each holding one shift of ShiftHours starting at the handoff time.
+type Definition struct {
+	Members    []uint
+	ShiftHours int
+	// StartDate (YYYY-MM-DD) and Handoff (HH:MM) are wall-clock values in
+	// TimeZone. Shifts that are whole days keep the handoff at the same
+	// local time across DST changes.
+	StartDate string
+	Handoff   string
+	TimeZone  string
+}
+
+// Exclusion takes a member out of the rotation for a period.
+type Exclusion struct {
+	UserID uint
+	Start  time.Time
+	End    time.Time
+}
+
+// Slot is one generated shift. UserID is nil when every member is excluded.
+type Slot struct {
+	Index  int
+	Start  time.Time
+	End    time.Time
+	UserID *uint
+}
+
+// Validate checks the definition can generate shifts.
+func (d Definition) Validate() error {
+	if len(d.Members) == 0 {
+		return errors.New("rotation needs at least one member")
+	}
+	if d.ShiftHours <= 0 {
+		return errors.New("shift length must be positive")
+	}
+	_, err := d.origin()
+	return err
+}
+
+// origin is the start of the first shift.
+func (d Definition) origin() (time.Time, error) {
+	loc, err := time.LoadLocation(d.TimeZone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time zone %q", d.TimeZone)
+	}
+	day, err := time.ParseInLocation(dateLayout, d.StartDate, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start date %q", d.StartDate)
+	}
+	clock, err := time.Parse(clockLayout, d.Handoff)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid handoff time %q", d.Handoff)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc), nil
+}
+
+// shiftStart returns when shift i begins.
+func (d Definition) shiftStart(origin time.Time, i int) time.Time {
+	if d.ShiftHours%24 == 0 {
+		return origin.AddDate(0, 0, i*d.ShiftHours/24)
+	}
+	return origin.Add(time.Duration(i*d.ShiftHours) * time.Hour)
+}
+
+// Slots returns the shifts that start in [from, to). Members are assigned
+// round-robin from the start date, so the same index always maps to the same
+// member; an excluded member's shift goes to the next available member.
+func (d Definition) Slots(from, to time.Time, exclusions []Exclusion) ([]Slot, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	origin, _ := d.origin()
+
+	i := 0
+	if from.After(origin) {
+		// jump close to from, then step forward to the first shift in range
+		i = int(from.Sub(origin)/(time.Duration(d.ShiftHours)*time.Hour)) - 1
+		if i < 0 {
+			i = 0
+		}
+	}
+
+	var out []Slot
+	for ; ; i++ {
+		start := d.shiftStart(origin, i)
+		if !start.Before(to) {
+			break
+		}
+		if start.Before(from) {
+			continue
+		}
+		end := d.shiftStart(origin, i+1)
+		slot := Slot{Index: i, Start: start, End: end}
+		for k := 0; k < len(d.Members); k++ {
+			member := d.Members[(i+k)%len(d.Members)]
+			if !excluded(member, start, end, exclusions) {
+				slot.UserID = &member
+				break
+			}
+		}
+		out = append(out, slot)
+	}
+	return out, nil
+}
+
+func excluded(userID uint, start, end time.Time, exclusions []Exclusion) bool {
+	for _, e := range exclusions {
+		if e.UserID == userID && e.Start.Before(end) && e.End.After(start) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package rotation
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	return loc
+}
+
+type wantSlot struct {
+	start string // local wall clock, 2006-01-02 15:04
+	hours float64
+	user  uint // 0 when nobody is available
+}
+
+func TestSlots(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	day := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	daily := Definition{Members: []uint{1, 2, 3}, ShiftHours: 24, StartDate: "2026-03-02", Handoff: "09:00", TimeZone: "America/New_York"}
+
+	tests := []struct {
+		name       string
+		def        Definition
+		from, to   time.Time
+		exclusions []Exclusion
+		want       []wantSlot
+	}{
+		{
+			name: "round robin from the start date",
+			def:  daily,
+			from: day("2026-03-01 00:00"), to: day("2026-03-06 00:00"),
+			want: []wantSlot{
+				{"2026-03-02 09:00", 24, 1},
+				{"2026-03-03 09:00", 24, 2},
+				{"2026-03-04 09:00", 24, 3},
+				{"2026-03-05 09:00", 24, 1},
+			},
+		},
+		{
+			// DST starts at 02:00 on 2026-03-08 in New York
+			name: "daily handoff keeps its local time across DST",
+			def:  daily,
+			from: day("2026-03-07 00:00"), to: day("2026-03-10 00:00"),
+			want: []wantSlot{
+				{"2026-03-07 09:00", 23, 3},
+				{"2026-03-08 09:00", 24, 1},
+				{"2026-03-09 09:00", 24, 2},
+			},
+		},
+		{
+			name: "hourly shifts run on absolute time across DST",
+			def:  Definition{Members: []uint{1, 2}, ShiftHours: 12, StartDate: "2026-03-07", Handoff: "21:00", TimeZone: "America/New_York"},
+			from: day("2026-03-07 00:00"), to: day("2026-03-08 22:00"),
+			want: []wantSlot{
+				{"2026-03-07 21:00", 12, 1},
+				{"2026-03-08 10:00", 12, 2},
+			},
+		},
+		{
+			name: "from inside an in-progress shift starts at the next handoff",
+			def:  daily,
+			from: day("2026-03-03 12:00"), to: day("2026-03-05 12:00"),
+			want: []wantSlot{
+				{"2026-03-04 09:00", 24, 3},
+				{"2026-03-05 09:00", 24, 1},
+			},
+		},
+		{
+			name: "an excluded member's shift goes to the next in line",
+			def:  daily,
+			from: day("2026-03-02 00:00"), to: day("2026-03-05 00:00"),
+			exclusions: []Exclusion{
+				{UserID: 2, Start: day("2026-03-03 12:00"), End: day("2026-03-03 13:00")},
+			},
+			want: []wantSlot{
+				{"2026-03-02 09:00", 24, 1},
+				{"2026-03-03 09:00", 24, 3},
+				{"2026-03-04 09:00", 24, 3},
+			},
+		},
+		{
+			name: "an exclusion touching a shift's edges doesn't count",
+			def:  daily,
+			from: day("2026-03-03 00:00"), to: day("2026-03-04 00:00"),
+			exclusions: []Exclusion{
+				{UserID: 2, Start: day("2026-03-02 09:00"), End: day("2026-03-03 09:00")},
+				{UserID: 2, Start: day("2026-03-04 09:00"), End: day("2026-03-05 09:00")},
+			},
+			want: []wantSlot{{"2026-03-03 09:00", 24, 2}},
+		},
+		{
+			name: "nobody available leaves the shift unassigned",
+			def:  daily,
+			from: day("2026-03-02 00:00"), to: day("2026-03-03 00:00"),
+			exclusions: []Exclusion{
+				{UserID: 1, Start: day("2026-03-02 00:00"), End: day("2026-03-04 00:00")},
+				{UserID: 2, Start: day("2026-03-02 00:00"), End: day("2026-03-04 00:00")},
+				{UserID: 3, Start: day("2026-03-02 00:00"), End: day("2026-03-04 00:00")},
+			},
+			want: []wantSlot{{"2026-03-02 09:00", 24, 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.def.Slots(tt.from, tt.to, tt.exclusions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d slots, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				s := got[i]
+				if start := s.Start.In(ny).Format("2006-01-02 15:04"); start != w.start {
+					t.Errorf("slot %d starts %s, want %s", i, start, w.start)
+				}
+				if h := s.End.Sub(s.Start).Hours(); h != w.hours {
+					t.Errorf("slot %d lasts %vh, want %vh", i, h, w.hours)
+				}
+				var user uint
+				if s.UserID != nil {
+					user = *s.UserID
+				}
+				if user != w.user {
+					t.Errorf("slot %d goes to %d, want %d", i, user, w.user)
+				}
+			}
+		})
+	}
+}
+
+func TestSlotsIndexIsStable(t *testing.T) {
+	def := Definition{Members: []uint{1, 2, 3}, ShiftHours: 8, StartDate: "2026-01-01", Handoff: "06:00", TimeZone: "UTC"}
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	all, err := def.Slots(from, from.AddDate(0, 0, 10), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// starting later, even mid-shift, must give the same index and member
+	later, err := def.Slots(from.AddDate(0, 0, 5).Add(7*time.Hour), from.AddDate(0, 0, 10), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := len(all) - len(later)
+	for i, s := range later {
+		want := all[offset+i]
+		if s.Index != want.Index || !s.Start.Equal(want.Start) || *s.UserID != *want.UserID {
+			t.Fatalf("slot %d = %+v, want %+v", i, s, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	good := Definition{Members: []uint{1}, ShiftHours: 24, StartDate: "2026-03-02", Handoff: "09:00", TimeZone: "UTC"}
+	if err := good.Validate(); err != nil {
+		t.Fatalf("valid definition rejected: %v", err)
+	}
+	for name, mutate := range map[string]func(*Definition){
+		"no members":     func(d *Definition) { d.Members = nil },
+		"zero hours":     func(d *Definition) { d.ShiftHours = 0 },
+		"bad time zone":  func(d *Definition) { d.TimeZone = "Mars/Olympus" },
+		"bad start date": func(d *Definition) { d.StartDate = "2026-13-01" },
+		"bad handoff":    func(d *Definition) { d.Handoff = "9am" },
+	} {
+		d := good
+		mutate(&d)
+		if err := d.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
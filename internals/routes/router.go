@@ -17,15 +17,83 @@ func RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/signup", handlers.SignupHandler)
 	// Note: login route will be added in the next step
 	mux.HandleFunc("/api/login",handlers.Login)
+	mux.HandleFunc("/api/login/2fa", handlers.LoginMFA)
+	mux.HandleFunc("/api/verify-email", handlers.VerifyEmail)
+	mux.Handle("/api/verify-email/resend", middleware.AuthMiddleware(http.HandlerFunc(handlers.ResendVerification)))
 	//Protected routes
+	mux.Handle("/api/profile", middleware.AuthMiddleware(http.HandlerFunc(handlers.Profile)))
+	mux.Handle("/api/profile/avatar", middleware.AuthMiddleware(http.HandlerFunc(handlers.Avatar)))
+	mux.Handle("/api/account", middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteAccount)))
+	mux.Handle("/api/account/export", middleware.AuthMiddleware(http.HandlerFunc(handlers.ExportAccount)))
+	mux.Handle("/api/audit", middleware.AuthMiddleware(http.HandlerFunc(handlers.AuditLog)))
+	mux.Handle("/api/audit/export", middleware.AuthMiddleware(http.HandlerFunc(handlers.ExportAuditLog)))
+	mux.Handle("/api/audit/verify", middleware.AuthMiddleware(http.HandlerFunc(handlers.VerifyAuditLog)))
+	mux.Handle("/api/password", middleware.AuthMiddleware(http.HandlerFunc(handlers.ChangePassword)))
+	mux.Handle("/api/2fa/setup", middleware.AuthMiddleware(http.HandlerFunc(handlers.SetupTOTP)))
+	mux.Handle("/api/2fa/enable", middleware.AuthMiddleware(http.HandlerFunc(handlers.EnableTOTP)))
+	mux.Handle("/api/2fa/disable", middleware.AuthMiddleware(http.HandlerFunc(handlers.DisableTOTP)))
+	mux.Handle("/api/2fa/recovery-codes", middleware.AuthMiddleware(http.HandlerFunc(handlers.RegenerateRecoveryCodes)))
 	mux.Handle("/profile",middleware.AuthMiddleware(http.HandlerFunc(handlers.Dashboard)))
+	mux.Handle("/api/orgs", middleware.AuthMiddleware(http.HandlerFunc(handlers.Organizations)))
+	mux.Handle("/api/orgs/{id}/teams", middleware.AuthMiddleware(http.HandlerFunc(handlers.OrganizationTeams)))
+	mux.Handle("/api/orgs/{id}/owner", middleware.AuthMiddleware(http.HandlerFunc(handlers.OrganizationOwner)))
+	mux.Handle("/api/teams", middleware.AuthMiddleware(http.HandlerFunc(handlers.MyTeams)))
+	mux.Handle("/api/teams/{id}/members", middleware.AuthMiddleware(http.HandlerFunc(handlers.TeamMembers)))
+	mux.Handle("/api/teams/{id}/members/me", middleware.AuthMiddleware(http.HandlerFunc(handlers.LeaveTeam)))
+	mux.Handle("/api/teams/{id}/invitations", middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateTeamInvitation)))
+	mux.Handle("/api/teams/{id}/members/{userId}", middleware.AuthMiddleware(http.HandlerFunc(handlers.TeamMember)))
+	mux.Handle("/api/teams/{id}/members/{userId}/qualifications", middleware.AuthMiddleware(http.HandlerFunc(handlers.MemberQualifications)))
+	mux.Handle("/api/events/{id}/qualifications", middleware.AuthMiddleware(http.HandlerFunc(handlers.EventQualifications)))
+	mux.Handle("/api/teams/{id}/role", middleware.AuthMiddleware(http.HandlerFunc(handlers.MyTeamRole)))
+	mux.Handle("/api/events/{id}/reassign", middleware.AuthMiddleware(http.HandlerFunc(handlers.ReassignEvent)))
+	mux.Handle("/api/swaps/{id}/revert", middleware.AuthMiddleware(http.HandlerFunc(handlers.RevertSwap)))
+	mux.Handle("/api/teams/{id}/settings", middleware.AuthMiddleware(http.HandlerFunc(handlers.TeamSettings)))
+	mux.Handle("/api/teams/{id}/labor-rules", middleware.AuthMiddleware(http.HandlerFunc(handlers.LaborRules)))
+	mux.Handle("/api/teams/{id}/swap-policy", middleware.AuthMiddleware(http.HandlerFunc(handlers.SwapPolicy)))
+	mux.Handle("/api/teams/{id}/blackouts", middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateBlackout)))
+	mux.Handle("/api/teams/{id}/blackouts/{blackoutId}", middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteBlackout)))
+	mux.Handle("/api/events/{id}/tradability", middleware.AuthMiddleware(http.HandlerFunc(handlers.EventTradability)))
+	mux.Handle("/api/teams/{id}/open-shifts", middleware.AuthMiddleware(http.HandlerFunc(handlers.OpenShifts)))
+	mux.Handle("/api/events/{id}/claim", middleware.AuthMiddleware(middleware.RequireVerifiedEmail(http.HandlerFunc(handlers.ClaimShift))))
+	mux.Handle("/api/events/{id}/claims", middleware.AuthMiddleware(http.HandlerFunc(handlers.ShiftClaims)))
+	mux.Handle("/api/events/{id}/claims/{claimId}/grant", middleware.AuthMiddleware(http.HandlerFunc(handlers.GrantShiftClaim)))
+	mux.Handle("/api/teams/{id}/rotations", middleware.AuthMiddleware(http.HandlerFunc(handlers.Rotations)))
+	mux.Handle("/api/rotations/{id}", middleware.AuthMiddleware(http.HandlerFunc(handlers.RotationDetail)))
+	mux.Handle("/api/rotations/{id}/generate", middleware.AuthMiddleware(http.HandlerFunc(handlers.GenerateRotation)))
+	mux.Handle("/api/rotations/{id}/exclusions", middleware.AuthMiddleware(http.HandlerFunc(handlers.RotationExclusions)))
+	mux.Handle("/api/rotations/{id}/exclusions/{exclusionId}", middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteRotationExclusion)))
+	mux.Handle("/api/availability/blocks", middleware.AuthMiddleware(http.HandlerFunc(handlers.AvailabilityBlocks)))
+	mux.Handle("/api/availability/blocks/{id}", middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteAvailabilityBlock)))
+	mux.Handle("/api/availability/preferred-hours", middleware.AuthMiddleware(http.HandlerFunc(handlers.PreferredHoursHandler)))
+	mux.Handle("/api/freebusy", middleware.AuthMiddleware(http.HandlerFunc(handlers.FreeBusy)))
+	mux.Handle("/api/freebusy/find", middleware.AuthMiddleware(http.HandlerFunc(handlers.FindAvailability)))
+	mux.Handle("/api/calendar", middleware.AuthMiddleware(http.HandlerFunc(handlers.CalendarView)))
+	mux.Handle("/api/events/trash", middleware.AuthMiddleware(http.HandlerFunc(handlers.EventTrash)))
+	mux.Handle("/api/events/{id}/restore", middleware.AuthMiddleware(http.HandlerFunc(handlers.RestoreEvent)))
+	mux.Handle("/api/events/{id}/history", middleware.AuthMiddleware(http.HandlerFunc(handlers.EventHistory)))
+	mux.Handle("/api/notifications", middleware.AuthMiddleware(http.HandlerFunc(handlers.Notifications)))
+	mux.Handle("/api/notifications/unread-count", middleware.AuthMiddleware(http.HandlerFunc(handlers.UnreadNotificationCount)))
+	mux.Handle("/api/notifications/read-all", middleware.AuthMiddleware(http.HandlerFunc(handlers.MarkAllNotificationsRead)))
+	mux.Handle("/api/notifications/{id}", middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteNotification)))
+	mux.Handle("/api/notifications/{id}/read", middleware.AuthMiddleware(http.HandlerFunc(handlers.MarkNotificationRead)))
+	mux.Handle("/api/saved-searches", middleware.AuthMiddleware(http.HandlerFunc(handlers.SavedSearches)))
+	mux.Handle("/api/saved-searches/{id}", middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteSavedSearch)))
+	mux.Handle("/api/orgs/{id}/webhooks", middleware.AuthMiddleware(http.HandlerFunc(handlers.OrganizationWebhooks)))
+	mux.Handle("/api/webhooks/{id}", middleware.AuthMiddleware(http.HandlerFunc(handlers.WebhookDetail)))
+	mux.Handle("/api/webhooks/{id}/deliveries", middleware.AuthMiddleware(http.HandlerFunc(handlers.WebhookDeliveries)))
+	mux.Handle("/api/webhooks/{id}/deliveries/{deliveryId}/redeliver", middleware.AuthMiddleware(http.HandlerFunc(handlers.RedeliverWebhook)))
+	mux.Handle("/api/notifications/stream", middleware.TokenFromQuery(middleware.AuthMiddleware(http.HandlerFunc(handlers.NotificationStream))))
+	mux.Handle("/api/marketplace/ws", middleware.TokenFromQuery(middleware.AuthMiddleware(http.HandlerFunc(handlers.MarketplaceSocket))))
+	mux.Handle("/api/teams/{id}/approvals", middleware.AuthMiddleware(http.HandlerFunc(handlers.TeamApprovals)))
+	mux.Handle("/api/swaps/{id}/decision", middleware.AuthMiddleware(middleware.RequireVerifiedEmail(http.HandlerFunc(handlers.DecideSwap))))
+	mux.Handle("/api/invitations/accept", middleware.AuthMiddleware(middleware.RequireVerifiedEmail(http.HandlerFunc(handlers.AcceptInvitation))))
 	mux.Handle("/api/create/event",middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateEvent)))
 	mux.Handle("/api/events",middleware.AuthMiddleware(http.HandlerFunc(handlers.ListEvents)))
 	mux.Handle("/update-events/{id}",middleware.AuthMiddleware(http.HandlerFunc(handlers.UpdateEvent)))
 	mux.Handle("/delet-events/{id}",middleware.AuthMiddleware(http.HandlerFunc(handlers.DeletEvent)))
 	mux.Handle("/api/swappable-slots",middleware.AuthMiddleware(http.HandlerFunc(handlers.GetSwappableSlots)))
-	mux.Handle("/api/swap-req",middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateSwapRequest)))
-	mux.Handle("/api/swap-res",middleware.AuthMiddleware(http.HandlerFunc(handlers.RespondToSwap)))
+	mux.Handle("/api/swap-req",middleware.AuthMiddleware(middleware.RequireVerifiedEmail(http.HandlerFunc(handlers.CreateSwapRequest))))
+	mux.Handle("/api/swap-res",middleware.AuthMiddleware(middleware.RequireVerifiedEmail(http.HandlerFunc(handlers.RespondToSwap))))
 
 
 
@@ -0,0 +1,233 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Severity says whether a violation blocks the action or only warns.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Shift is one block of work in a user's schedule.
+type Shift struct {
+	EventID uint
+	Start   time.Time
+	End     time.Time
+}
+
+// Violation is a single broken rule for one user. Start and End bound the
+// stretch of the schedule at fault and Amount measures how badly the rule
+// is broken there; a larger Amount is always worse.
+type Violation struct {
+	Rule     string    `json:"rule"`
+	UserID   uint      `json:"userId"`
+	Severity Severity  `json:"severity"`
+	Message  string    `json:"message"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Amount   float64   `json:"amount"`
+}
+
+// Covers reports whether v already accounts for other: the same rule,
+// broken over an overlapping stretch, at least as badly.
+func (v Violation) Covers(other Violation) bool {
+	return v.Rule == other.Rule && v.Start.Before(other.End) && other.Start.Before(v.End) &&
+		v.Amount >= other.Amount
+}
+
+// Rule inspects one user's complete schedule. Implementations only report
+// problems; the engine decides their severity.
+type Rule interface {
+	Name() string
+	Check(userID uint, schedule []Shift) []Violation
+}
+
+// Engine evaluates a set of rules with a single enforcement level.
+type Engine struct {
+	Rules    []Rule
+	Severity Severity
+}
+
+// Evaluate runs every rule against the schedule, sorted by start time.
+func (e Engine) Evaluate(userID uint, schedule []Shift) []Violation {
+	sorted := append([]Shift(nil), schedule...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var out []Violation
+	for _, r := range e.Rules {
+		for _, v := range r.Check(userID, sorted) {
+			v.Rule = r.Name()
+			v.UserID = userID
+			v.Severity = e.Severity
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Lookback is how far around a changed shift the schedule must be loaded for
+// every rule in the engine to see all shifts that could be affected.
+func (e Engine) Lookback() time.Duration {
+	d := 8 * 24 * time.Hour
+	for _, r := range e.Rules {
+		if c, ok := r.(MaxConsecutiveDays); ok {
+			if w := time.Duration(c.Days+2) * 24 * time.Hour; w > d {
+				d = w
+			}
+		}
+	}
+	return d
+}
+
+// MaxHoursPerWeek caps the hours worked in any ISO week (UTC).
+type MaxHoursPerWeek struct {
+	Hours float64
+}
+
+func (MaxHoursPerWeek) Name() string { return "max_hours_per_week" }
+
+func (r MaxHoursPerWeek) Check(userID uint, schedule []Shift) []Violation {
+	weeks := map[string]time.Duration{}
+	starts := map[string]time.Time{}
+	var order []string
+	for _, s := range schedule {
+		// split shifts that cross midnight on Sunday into their two weeks
+		for start := s.Start.UTC(); start.Before(s.End); {
+			y, w := start.ISOWeek()
+			key := fmt.Sprintf("%d-W%02d", y, w)
+			end := nextWeekStart(start)
+			if _, seen := weeks[key]; !seen {
+				order = append(order, key)
+				starts[key] = end.AddDate(0, 0, -7)
+			}
+			if end.After(s.End) {
+				end = s.End
+			}
+			weeks[key] += end.Sub(start)
+			start = end
+		}
+	}
+
+	var out []Violation
+	limit := time.Duration(r.Hours * float64(time.Hour))
+	for _, key := range order {
+		if weeks[key] > limit {
+			out = append(out, Violation{
+				Message: fmt.Sprintf("%.1f hours scheduled in week %s, limit is %.1f", weeks[key].Hours(), key, r.Hours),
+				Start:   starts[key],
+				End:     starts[key].AddDate(0, 0, 7),
+				Amount:  weeks[key].Hours(),
+			})
+		}
+	}
+	return out
+}
+
+func nextWeekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := (8 - int(day.Weekday())) % 7
+	if offset == 0 {
+		offset = 7
+	}
+	return day.AddDate(0, 0, offset)
+}
+
+// MinRestBetweenShifts requires a gap between the end of one shift and the
+// start of the next. Each shift is measured from the latest end of the shifts
+// before it, so a long shift overlapping several later ones is caught even
+// when shorter shifts sit in between.
+type MinRestBetweenShifts struct {
+	Hours float64
+}
+
+func (MinRestBetweenShifts) Name() string { return "min_rest_between_shifts" }
+
+func (r MinRestBetweenShifts) Check(userID uint, schedule []Shift) []Violation {
+	var out []Violation
+	min := time.Duration(r.Hours * float64(time.Hour))
+	last := 0 // index of the shift so far that ends latest
+	for i := 1; i < len(schedule); i++ {
+		if schedule[i-1].End.After(schedule[last].End) {
+			last = i - 1
+		}
+		prev, cur := schedule[last], schedule[i]
+		gap := cur.Start.Sub(prev.End)
+		if gap < min {
+			out = append(out, Violation{
+				Message: fmt.Sprintf("only %.1f hours rest between shifts %d and %d, minimum is %.1f",
+					gap.Hours(), prev.EventID, cur.EventID, r.Hours),
+				// the gap itself, widened to an instant when shifts overlap
+				Start:  earliest(prev.End, cur.Start),
+				End:    latest(prev.End, cur.Start).Add(time.Nanosecond),
+				Amount: (min - gap).Hours(),
+			})
+		}
+	}
+	return out
+}
+
+// MaxConsecutiveDays limits runs of calendar days (UTC) with any work.
+type MaxConsecutiveDays struct {
+	Days int
+}
+
+func (MaxConsecutiveDays) Name() string { return "max_consecutive_days" }
+
+func (r MaxConsecutiveDays) Check(userID uint, schedule []Shift) []Violation {
+	days := map[time.Time]bool{}
+	for _, s := range schedule {
+		for d := truncateDay(s.Start); d.Before(s.End); d = d.AddDate(0, 0, 1) {
+			days[d] = true
+		}
+	}
+	sorted := make([]time.Time, 0, len(days))
+	for d := range days {
+		sorted = append(sorted, d)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	// report each run that goes over once, covering all of its days
+	var out []Violation
+	for i := 0; i < len(sorted); {
+		j := i + 1
+		for j < len(sorted) && sorted[j].Equal(sorted[j-1].AddDate(0, 0, 1)) {
+			j++
+		}
+		if run := j - i; run > r.Days {
+			out = append(out, Violation{
+				Message: fmt.Sprintf("%d consecutive working days from %s to %s, limit is %d",
+					run, sorted[i].Format("2006-01-02"), sorted[j-1].Format("2006-01-02"), r.Days),
+				Start:  sorted[i],
+				End:    sorted[j-1].AddDate(0, 0, 1),
+				Amount: float64(run),
+			})
+		}
+		i = j
+	}
+	return out
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
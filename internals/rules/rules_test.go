@@ -0,0 +1,159 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func at(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func shift(id uint, start, end string) Shift {
+	return Shift{EventID: id, Start: at(start), End: at(end)}
+}
+
+func evaluate(r Rule, schedule ...Shift) []Violation {
+	return Engine{Rules: []Rule{r}, Severity: SeverityError}.Evaluate(1, schedule)
+}
+
+func TestMaxHoursPerWeekSplitsAtSundayMidnight(t *testing.T) {
+	// 2026-03-08 is a Sunday; the shift puts 4 hours in each ISO week
+	overnight := shift(1, "2026-03-08 20:00", "2026-03-09 04:00")
+
+	if v := evaluate(MaxHoursPerWeek{Hours: 5}, overnight); len(v) != 0 {
+		t.Fatalf("8 hours split 4/4 broke a 5 hour limit: %+v", v)
+	}
+
+	v := evaluate(MaxHoursPerWeek{Hours: 3}, overnight)
+	if len(v) != 2 {
+		t.Fatalf("got %d violations, want one per week: %+v", len(v), v)
+	}
+	for i, want := range []time.Time{at("2026-03-02 00:00"), at("2026-03-09 00:00")} {
+		if !v[i].Start.Equal(want) || !v[i].End.Equal(want.AddDate(0, 0, 7)) {
+			t.Errorf("violation %d covers %s-%s, want the week from %s", i, v[i].Start, v[i].End, want)
+		}
+		if v[i].Amount != 4 {
+			t.Errorf("violation %d amount = %v, want 4", i, v[i].Amount)
+		}
+	}
+}
+
+func TestMaxHoursPerWeekCountsUTCWeeks(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	// Sunday 21:00-23:00 in New York is Monday 01:00-03:00 UTC
+	s := Shift{EventID: 1, Start: time.Date(2026, 3, 15, 21, 0, 0, 0, ny), End: time.Date(2026, 3, 15, 23, 0, 0, 0, ny)}
+	v := evaluate(MaxHoursPerWeek{Hours: 1}, s)
+	if len(v) != 1 || !v[0].Start.Equal(at("2026-03-16 00:00")) {
+		t.Fatalf("got %+v, want one violation in the UTC week from 2026-03-16", v)
+	}
+}
+
+func TestMinRestBetweenShifts(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule []Shift
+		want     []float64 // amounts
+		pairs    []string
+	}{
+		{
+			name:     "enough rest",
+			schedule: []Shift{shift(1, "2026-03-02 08:00", "2026-03-02 16:00"), shift(2, "2026-03-03 00:00", "2026-03-03 08:00")},
+		},
+		{
+			name:     "short gap",
+			schedule: []Shift{shift(1, "2026-03-02 08:00", "2026-03-02 16:00"), shift(2, "2026-03-02 18:00", "2026-03-02 22:00")},
+			want:     []float64{6},
+			pairs:    []string{"shifts 1 and 2"},
+		},
+		{
+			name:     "overlapping shifts",
+			schedule: []Shift{shift(1, "2026-03-02 08:00", "2026-03-02 16:00"), shift(2, "2026-03-02 15:00", "2026-03-02 20:00")},
+			want:     []float64{9},
+			pairs:    []string{"shifts 1 and 2"},
+		},
+		{
+			name: "long shift containing later ones",
+			schedule: []Shift{
+				shift(3, "2026-03-02 03:00", "2026-03-02 04:00"),
+				shift(1, "2026-03-02 00:00", "2026-03-02 10:00"),
+				shift(2, "2026-03-02 01:00", "2026-03-02 02:00"),
+			},
+			want:  []float64{17, 15},
+			pairs: []string{"shifts 1 and 2", "shifts 1 and 3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := evaluate(MinRestBetweenShifts{Hours: 8}, tt.schedule...)
+			if len(v) != len(tt.want) {
+				t.Fatalf("got %d violations, want %d: %+v", len(v), len(tt.want), v)
+			}
+			for i := range v {
+				if v[i].Amount != tt.want[i] {
+					t.Errorf("violation %d amount = %v, want %v", i, v[i].Amount, tt.want[i])
+				}
+				if !strings.Contains(v[i].Message, tt.pairs[i]) {
+					t.Errorf("violation %d message %q doesn't name %s", i, v[i].Message, tt.pairs[i])
+				}
+				if !v[i].Start.Before(v[i].End) {
+					t.Errorf("violation %d has an empty period", i)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxConsecutiveDays(t *testing.T) {
+	schedule := []Shift{
+		shift(1, "2026-03-02 09:00", "2026-03-02 17:00"),
+		// crosses midnight, so it works both the 3rd and the 4th
+		shift(2, "2026-03-03 22:00", "2026-03-04 02:00"),
+		// ends exactly at midnight, so the 6th is free
+		shift(3, "2026-03-05 20:00", "2026-03-06 00:00"),
+		shift(4, "2026-03-07 09:00", "2026-03-07 17:00"),
+	}
+
+	if v := evaluate(MaxConsecutiveDays{Days: 4}, schedule...); len(v) != 0 {
+		t.Fatalf("a 4 day run broke a 4 day limit: %+v", v)
+	}
+
+	v := evaluate(MaxConsecutiveDays{Days: 3}, schedule...)
+	if len(v) != 1 {
+		t.Fatalf("got %d violations, want the run once: %+v", len(v), v)
+	}
+	if !v[0].Start.Equal(at("2026-03-02 00:00")) || !v[0].End.Equal(at("2026-03-06 00:00")) || v[0].Amount != 4 {
+		t.Errorf("got %s-%s amount %v, want the 4 days from 2026-03-02", v[0].Start, v[0].End, v[0].Amount)
+	}
+}
+
+func TestViolationCovers(t *testing.T) {
+	base := Violation{Rule: "max_hours_per_week", Start: at("2026-03-02 00:00"), End: at("2026-03-09 00:00"), Amount: 45}
+	tests := []struct {
+		name  string
+		other Violation
+		want  bool
+	}{
+		{"same violation", base, true},
+		{"milder in the same period", Violation{Rule: base.Rule, Start: base.Start, End: base.End, Amount: 41}, true},
+		{"worse in the same period", Violation{Rule: base.Rule, Start: base.Start, End: base.End, Amount: 50}, false},
+		{"overlapping period", Violation{Rule: base.Rule, Start: at("2026-03-08 00:00"), End: at("2026-03-10 00:00"), Amount: 45}, true},
+		{"touching period", Violation{Rule: base.Rule, Start: base.End, End: at("2026-03-16 00:00"), Amount: 41}, false},
+		{"other rule", Violation{Rule: "max_consecutive_days", Start: base.Start, End: base.End, Amount: 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := base.Covers(tt.other); got != tt.want {
+				t.Errorf("Covers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"os"
 	"time"
 
@@ -9,15 +10,38 @@ import (
 
 var jwtKey = []byte(os.Getenv("JWT_SECRET"))
 
+// Token types carried in the "typ" claim. Tokens issued before the claim
+// existed have no "typ" and are treated as access tokens.
+const (
+	TokenTypeAccess = "access"
+	TokenTypeMFA    = "mfa"
+)
+
+// mfaTokenTTL bounds how long a user has to enter their second factor.
+const mfaTokenTTL = 5 * time.Minute
+
 func GenerateToken(userID uint) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID,
+		"typ":     TokenTypeAccess,
 		"exp":     time.Now().Add(24 * time.Hour).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtKey)
 }
 
+// GenerateMFAToken issues the short-lived challenge token returned by login
+// when the account has two-factor authentication enabled.
+func GenerateMFAToken(userID uint) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"typ":     TokenTypeMFA,
+		"exp":     time.Now().Add(mfaTokenTTL).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtKey)
+}
+
 func VerifyToken(tokenStr string) (*jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
 		return jwtKey, nil
@@ -30,3 +54,27 @@ func VerifyToken(tokenStr string) (*jwt.MapClaims, error) {
 	}
 	return nil, err
 }
+
+// TokenType returns the "typ" claim, defaulting to an access token.
+func TokenType(claims *jwt.MapClaims) string {
+	if typ, ok := (*claims)["typ"].(string); ok && typ != "" {
+		return typ
+	}
+	return TokenTypeAccess
+}
+
+// VerifyMFAToken checks an MFA challenge token and returns its user ID.
+func VerifyMFAToken(tokenStr string) (uint, error) {
+	claims, err := VerifyToken(tokenStr)
+	if err != nil {
+		return 0, err
+	}
+	if TokenType(claims) != TokenTypeMFA {
+		return 0, errors.New("not an mfa token")
+	}
+	uid, ok := (*claims)["user_id"].(float64)
+	if !ok {
+		return 0, errors.New("invalid user id claim")
+	}
+	return uint(uid), nil
+}
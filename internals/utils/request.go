@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/jfernsio/slotswapper/internals/config"
+)
+
+// ClientIP returns the caller's IP address, honouring X-Forwarded-For only
+// when TRUST_PROXY is enabled.
+func ClientIP(r *http.Request) string {
+	if config.TrustProxy() {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
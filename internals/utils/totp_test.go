@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890".
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; ours are their last 6 digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("TOTPCode(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPCodeInvalidSecret(t *testing.T) {
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Fatal("expected an error for an invalid secret")
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+	tests := []struct {
+		name   string
+		offset int64
+		ok     bool
+	}{
+		{"two steps early", -2, false},
+		{"one step early", -1, true},
+		{"current step", 0, true},
+		{"one step late", 1, true},
+		{"two steps late", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := TOTPCode(rfcSecret, step+tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := ValidateTOTP(rfcSecret, code, now)
+			if ok != tt.ok {
+				t.Fatalf("ValidateTOTP ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && got != step+tt.offset {
+				t.Errorf("matched step %d, want %d", got, step+tt.offset)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPPeriodBoundary(t *testing.T) {
+	// the last second of a step still accepts the next step's code, and the
+	// first second of a step still accepts the previous one
+	start := time.Unix(1111111110, 0) // 1111111110 is a multiple of 30
+	step := start.Unix() / totpPeriod
+	next, _ := TOTPCode(rfcSecret, step+1)
+	if _, ok := ValidateTOTP(rfcSecret, next, start.Add(-time.Second)); ok {
+		t.Error("a code two steps ahead was accepted")
+	}
+	if _, ok := ValidateTOTP(rfcSecret, next, start); !ok {
+		t.Error("the next step's code was rejected")
+	}
+	prev, _ := TOTPCode(rfcSecret, step-1)
+	if _, ok := ValidateTOTP(rfcSecret, prev, start.Add(totpPeriod*time.Second-time.Second)); !ok {
+		t.Error("the previous step's code was rejected in the last second of the step")
+	}
+	if _, ok := ValidateTOTP(rfcSecret, prev, start.Add(totpPeriod*time.Second)); ok {
+		t.Error("a code two steps old was accepted")
+	}
+}
+
+func TestValidateTOTPRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "94287082"} {
+		if _, ok := ValidateTOTP(rfcSecret, code, now); ok {
+			t.Errorf("ValidateTOTP accepted %q", code)
+		}
+	}
+	if _, ok := ValidateTOTP(rfcSecret, " 287082 ", now); !ok {
+		t.Error("surrounding spaces should be ignored")
+	}
+}
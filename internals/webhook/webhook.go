@@ -0,0 +1,300 @@
+// Package webhook delivers an organization's swap and event changes to the
+// URLs it registered. Each change becomes a stored delivery per matching
+// webhook; a background worker POSTs them, retrying with backoff.
+//
+// Every request carries these headers:
+//
+//	X-SlotSwapper-Event:     the event type, e.g. swap.accepted
+//	X-SlotSwapper-Delivery:  the delivery ID
+//	X-SlotSwapper-Timestamp: Unix seconds when the request was signed
+//	X-SlotSwapper-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// Receivers should recompute the signature with their secret, reject stale
+// timestamps, and use the payload's id to ignore repeats, since a delivery
+// may arrive more than once.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/jfernsio/slotswapper/internals/models"
+)
+
+// Event types a webhook can subscribe to.
+var EventTypes = []string{
+	"swap.created", "swap.accepted", "swap.awaiting_approval", "swap.rejected",
+	"swap.reverted", "swap.expired", "swap.cancelled",
+	"event.created", "event.updated", "event.deleted", "event.restored",
+	"event.reassigned", "event.claimed", "event.qualifications_updated",
+}
+
+// AllEvents subscribes a webhook to every event type.
+const AllEvents = "*"
+
+// deliveryLease is how long a claimed delivery is hidden from other workers
+// while it is being sent. It only has to outlast one request, since
+// deliveries are leased one at a time.
+const deliveryLease = 2 * time.Minute
+
+var ErrPrivateAddress = errors.New("webhook target resolves to a private address")
+
+// ValidEventType reports whether a webhook may subscribe to t.
+func ValidEventType(t string) bool {
+	return t == AllEvents || slices.Contains(EventTypes, t)
+}
+
+// NewSecret returns a random signing secret.
+func NewSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// Sign returns the signature header value for body sent at ts.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatch stores a delivery of the change for every active webhook of the
+// team's organization that wants typ. Team-less changes have no
+// organization and go nowhere. Call it once the change has committed.
+func Dispatch(db *gorm.DB, teamID *uint, typ string, data models.JSONMap) error {
+	if teamID == nil {
+		return nil
+	}
+	var team models.Team
+	if err := db.Select("organization_id").First(&team, *teamID).Error; err != nil {
+		return err
+	}
+	var hooks []models.Webhook
+	if err := db.Where("organization_id = ? AND active = ?", team.OrganizationID, true).
+		Where("? = ANY(events) OR ? = ANY(events)", typ, AllEvents).
+		Find(&hooks).Error; err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	now := time.Now().UTC()
+	payload := models.JSONMap{
+		"id":             "evt_" + hex.EncodeToString(id),
+		"type":           typ,
+		"createdAt":      now.Format(time.RFC3339Nano),
+		"organizationId": team.OrganizationID,
+		"teamId":         *teamID,
+		"data":           data,
+	}
+	// stored as sent, so redeliveries carry the same body
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var stored models.JSONMap
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return err
+	}
+
+	deliveries := make([]models.WebhookDelivery, len(hooks))
+	for i, h := range hooks {
+		deliveries[i] = models.WebhookDelivery{
+			WebhookID:     h.ID,
+			EventID:       payload["id"].(string),
+			EventType:     typ,
+			Payload:       stored,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+		}
+	}
+	return db.Create(&deliveries).Error
+}
+
+// Redeliver queues a fresh copy of a delivery to be sent straight away.
+func Redeliver(db *gorm.DB, d models.WebhookDelivery) (models.WebhookDelivery, error) {
+	again := models.WebhookDelivery{
+		WebhookID:     d.WebhookID,
+		EventID:       d.EventID,
+		EventType:     d.EventType,
+		Payload:       d.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: time.Now(),
+		RedeliveryOf:  &d.ID,
+	}
+	err := db.Create(&again).Error
+	return again, err
+}
+
+// NewClient returns the HTTP client deliveries are sent with. Unless
+// allowPrivate is set it refuses to connect to loopback, private and
+// link-local addresses, however the URL's host resolves.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		// a redirect would bypass the URL the organization registered
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// retryDelay doubles from 30 seconds after each failed attempt, up to six
+// hours.
+func retryDelay(attempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempts && d < 6*time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, 6*time.Hour)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// send POSTs one delivery and reports the response status and body.
+func send(ctx context.Context, client *http.Client, hook models.Webhook, d models.WebhookDelivery) (int, string, error) {
+	body, err := json.Marshal(d.Payload)
+	if err != nil {
+		return 0, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SlotSwapper-Webhook/1.0")
+	req.Header.Set("X-SlotSwapper-Event", d.EventType)
+	req.Header.Set("X-SlotSwapper-Delivery", strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set("X-SlotSwapper-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-SlotSwapper-Signature", Sign(hook.Secret, ts, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 1000))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(snippet), fmt.Errorf("endpoint answered %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(snippet), nil
+}
+
+// ProcessDeliveries sends up to batch due deliveries and returns how many
+// succeeded. Each delivery is leased just before it is sent so several
+// processes can share the work, however long the batch takes. A delivery
+// whose webhook was disabled is marked failed.
+func ProcessDeliveries(ctx context.Context, db *gorm.DB, client *http.Client, now time.Time, batch, maxAttempts int) (int, error) {
+	succeeded := 0
+	hooks := map[uint]*models.Webhook{}
+	for range batch {
+		d, err := leaseDelivery(db, now)
+		if err != nil || d == nil {
+			return succeeded, err
+		}
+		hook, ok := hooks[d.WebhookID]
+		if !ok {
+			var h models.Webhook
+			if err := db.First(&h, d.WebhookID).Error; err == nil {
+				hook = &h
+			}
+			hooks[d.WebhookID] = hook
+		}
+
+		updates := map[string]interface{}{}
+		if hook == nil || !hook.Active {
+			updates["status"] = models.DeliveryFailed
+			updates["last_error"] = "webhook disabled or removed"
+		} else {
+			status, body, err := send(ctx, client, *hook, *d)
+			updates["attempts"] = d.Attempts + 1
+			updates["response_status"] = status
+			updates["response_body"] = truncate(body, 1000)
+			switch {
+			case err == nil:
+				succeeded++
+				updates["status"] = models.DeliverySucceeded
+				updates["delivered_at"] = time.Now()
+				updates["last_error"] = ""
+			case d.Attempts+1 >= maxAttempts:
+				log.Printf("giving up on webhook delivery %d after %d attempts: %v", d.ID, d.Attempts+1, err)
+				updates["status"] = models.DeliveryFailed
+				updates["last_error"] = truncate(err.Error(), 500)
+			default:
+				updates["next_attempt_at"] = time.Now().Add(retryDelay(d.Attempts + 1))
+				updates["last_error"] = truncate(err.Error(), 500)
+			}
+		}
+		if err := db.Model(&models.WebhookDelivery{}).Where("id = ?", d.ID).Updates(updates).Error; err != nil {
+			return succeeded, err
+		}
+	}
+	return succeeded, nil
+}
+
+// leaseDelivery claims the next due delivery for deliveryLease, or returns
+// nil when nothing is due.
+func leaseDelivery(db *gorm.DB, now time.Time) (*models.WebhookDelivery, error) {
+	var due []models.WebhookDelivery
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at").Limit(1).Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id = ?", due[0].ID).
+			Update("next_attempt_at", time.Now().Add(deliveryLease)).Error
+	})
+	if err != nil || len(due) == 0 {
+		return nil, err
+	}
+	return &due[0], nil
+}
@@ -0,0 +1,341 @@
+// Package ws is a minimal server side of the WebSocket protocol (RFC 6455):
+// the upgrade handshake with an Origin check, unfragmented and fragmented
+// messages, ping/pong and the closing handshake. Text messages and close
+// reasons must be valid UTF-8. Extensions and subprotocols are not
+// supported.
+package ws
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Opcodes.
+const (
+	OpContinuation = 0
+	OpText         = 1
+	OpBinary       = 2
+	OpClose        = 8
+	OpPing         = 9
+	OpPong         = 10
+)
+
+// Close codes. CloseNoStatus is never sent: closing with it sends a close
+// frame without a code, as the reply to a peer that gave none.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseTryAgainLater   = 1013
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	ErrClosed        = errors.New("websocket: connection closed")
+	ErrProtocol      = errors.New("websocket: protocol error")
+	ErrMessageTooBig = errors.New("websocket: message too big")
+	ErrInvalidUTF8   = errors.New("websocket: invalid UTF-8")
+	ErrBadOrigin     = errors.New("websocket: origin not allowed")
+)
+
+// Conn is an upgraded connection. Reads must come from one goroutine;
+// writes may come from any.
+type Conn struct {
+	// MaxMessageSize caps incoming messages, in bytes.
+	MaxMessageSize int64
+	// WriteTimeout bounds each write so a stalled client can't block the
+	// writer forever.
+	WriteTimeout time.Duration
+	// PongHandler, if set, is called for every pong received.
+	PongHandler func()
+
+	conn      net.Conn
+	br        *bufio.Reader
+	wmu       sync.Mutex
+	closeOnce sync.Once
+}
+
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// originAllowed accepts requests without an Origin (non-browser clients),
+// from the server's own host, or from one of origins, given as
+// scheme://host[:port].
+func originAllowed(r *http.Request, origins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, o := range origins {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// validCloseCode reports whether a peer may send code in a close frame.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// Upgrade completes the handshake and takes over the connection. Browser
+// requests must come from the server's own origin or one of origins. On
+// failure it has already written an HTTP error response.
+func Upgrade(w http.ResponseWriter, r *http.Request, origins ...string) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, ErrProtocol
+	}
+	if !originAllowed(r, origins) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return nil, ErrBadOrigin
+	}
+	if !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, ErrProtocol
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, ErrProtocol
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, ErrProtocol
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return nil, ErrProtocol
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(resp)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{
+		MaxMessageSize: 64 << 10,
+		WriteTimeout:   10 * time.Second,
+		conn:           conn,
+		br:             brw.Reader,
+	}, nil
+}
+
+// SetReadDeadline sets when a pending or future read times out.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// and pongs passed to PongHandler along the way. When the peer closes, the
+// close is echoed and ErrClosed returned; a malformed close fails the
+// connection with a protocol error instead.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var op int
+	var msg []byte
+	for {
+		fin, frameOp, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch frameOp {
+		case OpPing:
+			if err := c.writeFrame(OpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			if c.PongHandler != nil {
+				c.PongHandler()
+			}
+			continue
+		case OpClose:
+			code := CloseNoStatus
+			switch {
+			case len(payload) == 1:
+				return 0, nil, c.fail(ErrProtocol, CloseProtocolError)
+			case len(payload) >= 2:
+				code = int(binary.BigEndian.Uint16(payload))
+				if !validCloseCode(code) || !utf8.Valid(payload[2:]) {
+					return 0, nil, c.fail(ErrProtocol, CloseProtocolError)
+				}
+			}
+			c.Close(code, "")
+			return 0, nil, ErrClosed
+		case OpContinuation:
+			if op == 0 {
+				return 0, nil, c.fail(ErrProtocol, CloseProtocolError)
+			}
+		case OpText, OpBinary:
+			if op != 0 {
+				return 0, nil, c.fail(ErrProtocol, CloseProtocolError)
+			}
+			op = frameOp
+		default:
+			return 0, nil, c.fail(ErrProtocol, CloseProtocolError)
+		}
+		if int64(len(msg)+len(payload)) > c.MaxMessageSize {
+			return 0, nil, c.fail(ErrMessageTooBig, CloseMessageTooBig)
+		}
+		msg = append(msg, payload...)
+		if fin {
+			if op == OpText && !utf8.Valid(msg) {
+				return 0, nil, c.fail(ErrInvalidUTF8, CloseInvalidPayload)
+			}
+			return op, msg, nil
+		}
+	}
+}
+
+func (c *Conn) readFrame() (bool, int, []byte, error) {
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := h[0]&0x80 != 0
+	op := int(h[0] & 0x0f)
+	if h[0]&0x70 != 0 || h[1]&0x80 == 0 {
+		// reserved bits need an extension; clients must mask
+		return false, 0, nil, c.fail(ErrProtocol, CloseProtocolError)
+	}
+	n := int64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if op >= OpClose && (n > 125 || !fin) {
+		return false, 0, nil, c.fail(ErrProtocol, CloseProtocolError)
+	}
+	if n < 0 || n > c.MaxMessageSize {
+		return false, 0, nil, c.fail(ErrMessageTooBig, CloseMessageTooBig)
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// WriteMessage sends one unfragmented message.
+func (c *Conn) WriteMessage(op int, data []byte) error {
+	return c.writeFrame(op, data)
+}
+
+// Ping sends a ping; the peer's pong reaches PongHandler.
+func (c *Conn) Ping() error {
+	return c.writeFrame(OpPing, nil)
+}
+
+func (c *Conn) writeFrame(op int, data []byte) error {
+	header := []byte{0x80 | byte(op), 0}
+	switch n := len(data); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.WriteTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout))
+	}
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// fail closes the connection with code and returns err.
+func (c *Conn) fail(err error, code int) error {
+	c.Close(code, "")
+	return err
+}
+
+// Close sends a close frame with code and reason, then closes the
+// connection. Later calls do nothing.
+func (c *Conn) Close(code int, reason string) error {
+	var err error
+	c.closeOnce.Do(func() {
+		var payload []byte
+		if code != CloseNoStatus {
+			if len(reason) > 123 {
+				reason = strings.ToValidUTF8(reason[:123], "")
+			}
+			payload = append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+		}
+		c.writeFrame(OpClose, payload)
+		err = c.conn.Close()
+	})
+	return err
+}
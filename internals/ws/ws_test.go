@@ -0,0 +1,369 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer upgrades every request and echoes messages back until the
+// connection ends. The error that ended each connection is sent on the
+// returned channel.
+func echoServer(t *testing.T, maxSize int64, origins ...string) (*httptest.Server, chan error) {
+	t.Helper()
+	errs := make(chan error, 16)
+	report := func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, origins...)
+		if err != nil {
+			report(err)
+			return
+		}
+		if maxSize > 0 {
+			conn.MaxMessageSize = maxSize
+		}
+		conn.PongHandler = func() { conn.WriteMessage(OpText, []byte("pong seen")) }
+		for {
+			op, msg, err := conn.ReadMessage()
+			if err != nil {
+				report(err)
+				return
+			}
+			if err := conn.WriteMessage(op, msg); err != nil {
+				report(err)
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, errs
+}
+
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func handshake(t *testing.T, srv *httptest.Server, origin string) (*client, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req := "GET / HTTP/1.1\r\nHost: " + srv.Listener.Addr().String() + "\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n"
+	if origin != "" {
+		req += "Origin: " + origin + "\r\n"
+	}
+	if _, err := conn.Write([]byte(req + "\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &client{t: t, conn: conn, br: br}, resp
+}
+
+func dial(t *testing.T, srv *httptest.Server) *client {
+	t.Helper()
+	c, resp := handshake(t, srv, "")
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d", resp.StatusCode)
+	}
+	return c
+}
+
+// send writes one frame, masked unless unmasked is set.
+func (c *client) send(fin bool, op int, payload []byte, unmasked bool) {
+	c.t.Helper()
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	maskBit := byte(0x80)
+	if unmasked {
+		maskBit = 0
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = binary.BigEndian.AppendUint16(append(frame, maskBit|126), uint16(n))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, maskBit|127), uint64(n))
+	}
+	body := append([]byte(nil), payload...)
+	if !unmasked {
+		mask := []byte{0x12, 0x34, 0x56, 0x78}
+		frame = append(frame, mask...)
+		for i := range body {
+			body[i] ^= mask[i%4]
+		}
+	}
+	if _, err := c.conn.Write(append(frame, body...)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// recv reads one frame from the server, which must not be masked.
+func (c *client) recv() (bool, int, []byte) {
+	c.t.Helper()
+	var h [2]byte
+	c.read(h[:])
+	if h[1]&0x80 != 0 {
+		c.t.Fatal("server frame is masked")
+	}
+	n := int(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		c.read(ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		c.read(ext[:])
+		n = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, n)
+	c.read(payload)
+	return h[0]&0x80 != 0, int(h[0] & 0x0f), payload
+}
+
+func (c *client) read(b []byte) {
+	c.t.Helper()
+	if _, err := io.ReadFull(c.br, b); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) expect(op int, payload string) {
+	c.t.Helper()
+	fin, gotOp, got := c.recv()
+	if !fin || gotOp != op || string(got) != payload {
+		c.t.Fatalf("got frame fin=%v op=%d %q, want op=%d %q", fin, gotOp, got, op, payload)
+	}
+}
+
+// expectClose reads the server's close frame and checks its code; code 0
+// means a close frame without a code.
+func (c *client) expectClose(code int) {
+	c.t.Helper()
+	_, op, payload := c.recv()
+	if op != OpClose {
+		c.t.Fatalf("got op %d, want close", op)
+	}
+	if code == 0 {
+		if len(payload) != 0 {
+			c.t.Fatalf("close payload = %v, want none", payload)
+		}
+		return
+	}
+	if len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != code {
+		c.t.Fatalf("close payload = %v, want code %d", payload, code)
+	}
+}
+
+func expectErr(t *testing.T, errs chan error, want error) {
+	t.Helper()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, want) {
+			t.Fatalf("server error = %v, want %v", err, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not finish")
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	srv, _ := echoServer(t, 0)
+	_, resp := handshake(t, srv, "")
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	// the worked example from RFC 6455 section 1.3
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	srv, _ := echoServer(t, 0, "https://app.example.com")
+	tests := []struct {
+		origin string
+		status int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{srv.URL, http.StatusSwitchingProtocols},
+		{"https://app.example.com", http.StatusSwitchingProtocols},
+		{"https://evil.example.com", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		_, resp := handshake(t, srv, tt.origin)
+		if resp.StatusCode != tt.status {
+			t.Errorf("origin %q: status = %d, want %d", tt.origin, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestMaskedMessagesAreEchoed(t *testing.T) {
+	srv, _ := echoServer(t, 0)
+	c := dial(t, srv)
+	c.send(true, OpText, []byte("hello"), false)
+	c.expect(OpText, "hello")
+
+	long := strings.Repeat("x", 300)
+	c.send(true, OpBinary, []byte(long), false)
+	c.expect(OpBinary, long)
+}
+
+func TestUnmaskedFrameIsRejected(t *testing.T) {
+	srv, errs := echoServer(t, 0)
+	c := dial(t, srv)
+	c.send(true, OpText, []byte("hello"), true)
+	c.expectClose(CloseProtocolError)
+	expectErr(t, errs, ErrProtocol)
+}
+
+func TestFragmentedMessageWithInterleavedPing(t *testing.T) {
+	srv, _ := echoServer(t, 0)
+	c := dial(t, srv)
+	c.send(false, OpText, []byte("Hel"), false)
+	c.send(true, OpPing, []byte("are you there"), false)
+	c.expect(OpPong, "are you there")
+	c.send(false, OpContinuation, []byte("lo, "), false)
+	c.send(true, OpContinuation, []byte("world"), false)
+	c.expect(OpText, "Hello, world")
+}
+
+func TestPongReachesHandler(t *testing.T) {
+	srv, _ := echoServer(t, 0)
+	c := dial(t, srv)
+	c.send(true, OpPong, nil, false)
+	c.expect(OpText, "pong seen")
+}
+
+func TestBadFragmentationIsRejected(t *testing.T) {
+	t.Run("continuation without start", func(t *testing.T) {
+		srv, errs := echoServer(t, 0)
+		c := dial(t, srv)
+		c.send(true, OpContinuation, []byte("x"), false)
+		c.expectClose(CloseProtocolError)
+		expectErr(t, errs, ErrProtocol)
+	})
+	t.Run("new message inside fragmented one", func(t *testing.T) {
+		srv, errs := echoServer(t, 0)
+		c := dial(t, srv)
+		c.send(false, OpText, []byte("a"), false)
+		c.send(true, OpText, []byte("b"), false)
+		c.expectClose(CloseProtocolError)
+		expectErr(t, errs, ErrProtocol)
+	})
+}
+
+func TestBadControlFramesAreRejected(t *testing.T) {
+	t.Run("too long", func(t *testing.T) {
+		srv, errs := echoServer(t, 0)
+		c := dial(t, srv)
+		c.send(true, OpPing, bytes.Repeat([]byte("p"), 126), false)
+		c.expectClose(CloseProtocolError)
+		expectErr(t, errs, ErrProtocol)
+	})
+	t.Run("fragmented", func(t *testing.T) {
+		srv, errs := echoServer(t, 0)
+		c := dial(t, srv)
+		c.send(false, OpPing, []byte("p"), false)
+		c.expectClose(CloseProtocolError)
+		expectErr(t, errs, ErrProtocol)
+	})
+	t.Run("reserved opcode", func(t *testing.T) {
+		srv, errs := echoServer(t, 0)
+		c := dial(t, srv)
+		c.send(true, 0xB, nil, false)
+		c.expectClose(CloseProtocolError)
+		expectErr(t, errs, ErrProtocol)
+	})
+}
+
+func TestOversizeMessagesAreRejected(t *testing.T) {
+	t.Run("single frame", func(t *testing.T) {
+		srv, errs := echoServer(t, 16)
+		c := dial(t, srv)
+		c.send(true, OpText, bytes.Repeat([]byte("x"), 17), false)
+		c.expectClose(CloseMessageTooBig)
+		expectErr(t, errs, ErrMessageTooBig)
+	})
+	t.Run("across fragments", func(t *testing.T) {
+		srv, errs := echoServer(t, 16)
+		c := dial(t, srv)
+		c.send(false, OpText, bytes.Repeat([]byte("x"), 10), false)
+		c.send(true, OpContinuation, bytes.Repeat([]byte("x"), 10), false)
+		c.expectClose(CloseMessageTooBig)
+		expectErr(t, errs, ErrMessageTooBig)
+	})
+}
+
+func TestTextMustBeUTF8(t *testing.T) {
+	srv, errs := echoServer(t, 0)
+	c := dial(t, srv)
+	// a character split across fragments is fine once reassembled
+	euro := []byte("€")
+	c.send(false, OpText, euro[:1], false)
+	c.send(true, OpContinuation, euro[1:], false)
+	c.expect(OpText, "€")
+
+	c.send(true, OpText, []byte{0xff, 0xfe}, false)
+	c.expectClose(CloseInvalidPayload)
+	expectErr(t, errs, ErrInvalidUTF8)
+}
+
+func TestClosingHandshake(t *testing.T) {
+	closeFrame := func(code int, reason string) []byte {
+		return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+	}
+	tests := []struct {
+		name    string
+		payload []byte
+		reply   int
+		err     error
+	}{
+		{"normal", closeFrame(CloseNormal, "bye"), CloseNormal, ErrClosed},
+		{"application code", closeFrame(4000, ""), 4000, ErrClosed},
+		{"no code", nil, 0, ErrClosed},
+		{"one byte", []byte{0x03}, CloseProtocolError, ErrProtocol},
+		{"reserved code", closeFrame(CloseNoStatus, ""), CloseProtocolError, ErrProtocol},
+		{"abnormal code", closeFrame(1006, ""), CloseProtocolError, ErrProtocol},
+		{"invalid reason", append(closeFrame(CloseNormal, ""), 0xff), CloseProtocolError, ErrProtocol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, errs := echoServer(t, 0)
+			c := dial(t, srv)
+			c.send(true, OpClose, tt.payload, false)
+			c.expectClose(tt.reply)
+			expectErr(t, errs, tt.err)
+		})
+	}
+}